@@ -0,0 +1,169 @@
+package wadlib
+
+import (
+	"crypto/rand"
+	"errors"
+)
+
+var (
+	ErrNoContents       = errors.New("WAD must hold at least one content")
+	ErrInvalidBootIndex = errors.New("boot index does not refer to any content")
+)
+
+// WADBuilder assists in creating a WAD from scratch, based off of the included templates.
+// Its methods may be chained, with the resulting WAD obtained via Build.
+type WADBuilder struct {
+	titleID      TitleID
+	titleVersion uint16
+	iosVersion   uint32
+	region       Region
+	bootIndex    uint16
+	titleKey     *[16]byte
+	keys         KeyProvider
+	contents     []builderContent
+}
+
+// builderContent describes a content to be added to the WAD.
+type builderContent struct {
+	data        []byte
+	id          uint32
+	contentType ContentType
+}
+
+// NewWADBuilder returns a WADBuilder with no contents.
+// Unless specified otherwise, a random title key is used.
+func NewWADBuilder() *WADBuilder {
+	return &WADBuilder{}
+}
+
+// TitleID sets the title ID of the WAD.
+func (b *WADBuilder) TitleID(titleID TitleID) *WADBuilder {
+	b.titleID = titleID
+	return b
+}
+
+// TitleVersion sets the version of the WAD's title.
+func (b *WADBuilder) TitleVersion(version uint16) *WADBuilder {
+	b.titleVersion = version
+	return b
+}
+
+// IOSVersion sets the IOS the title runs under, such as 58 for IOS58.
+func (b *WADBuilder) IOSVersion(ios uint32) *WADBuilder {
+	b.iosVersion = ios
+	return b
+}
+
+// Region sets the region of the title as listed within the TMD.
+func (b *WADBuilder) Region(region Region) *WADBuilder {
+	b.region = region
+	return b
+}
+
+// BootIndex sets the index of the content booted when the title is launched.
+func (b *WADBuilder) BootIndex(index uint16) *WADBuilder {
+	b.bootIndex = index
+	return b
+}
+
+// AddContent adds the given data as a content with the given ID and type.
+// Contents are indexed from 0 in the order they are added.
+func (b *WADBuilder) AddContent(data []byte, id uint32, contentType ContentType) *WADBuilder {
+	b.contents = append(b.contents, builderContent{
+		data:        data,
+		id:          id,
+		contentType: contentType,
+	})
+	return b
+}
+
+// TitleKey sets the decrypted title key used to encrypt contents.
+func (b *WADBuilder) TitleKey(titleKey [16]byte) *WADBuilder {
+	b.titleKey = &titleKey
+	return b
+}
+
+// RandomTitleKey specifies that a random title key should be used to encrypt contents.
+func (b *WADBuilder) RandomTitleKey() *WADBuilder {
+	b.titleKey = nil
+	return b
+}
+
+// KeyProvider sets the key provider used to encrypt the title key.
+// Unless specified otherwise, DefaultKeyProvider is used.
+func (b *WADBuilder) KeyProvider(keys KeyProvider) *WADBuilder {
+	b.keys = keys
+	return b
+}
+
+// Build creates a WAD with the given values and contents.
+// Its ticket and TMD are not signed. Consider using WAD.Fakesign or the Sign methods afterwards.
+// At least one content must be added, and the boot index must refer to one of them.
+func (b *WADBuilder) Build() (*WAD, error) {
+	if len(b.contents) == 0 {
+		return nil, ErrNoContents
+	}
+
+	wad := WAD{
+		CertificateChain: append([]byte{}, CertChainTemplate...),
+	}
+	wad.SetKeyProvider(b.keys)
+
+	err := wad.LoadTicket(TicketTemplate)
+	if err != nil {
+		return nil, err
+	}
+
+	err = wad.LoadTMD(TMDTemplate)
+	if err != nil {
+		return nil, err
+	}
+
+	wad.Ticket.TitleID = b.titleID
+	wad.Ticket.TitleVersion = b.titleVersion
+	wad.TMD.TitleID = b.titleID
+	wad.TMD.TitleVersion = b.titleVersion
+	wad.TMD.SetRegion(b.region)
+	wad.TMD.BootIndex = b.bootIndex
+
+	// The required IOS is specified as a title ID, with IOS being 00000001-xxxxxxxx.
+	wad.TMD.SetRequiredIOS(NewTitleID(uint32(CategorySystem), b.iosVersion))
+
+	// As the title ID is used as the IV for the title key,
+	// the title key must be set after the title ID.
+	var titleKey [16]byte
+	if b.titleKey != nil {
+		titleKey = *b.titleKey
+	} else {
+		_, err = rand.Read(titleKey[:])
+		if err != nil {
+			return nil, err
+		}
+	}
+	err = wad.Ticket.UpdateTitleKey(titleKey)
+	if err != nil {
+		return nil, err
+	}
+
+	// Remove the template's contents in favor of our own.
+	wad.TMD.Contents = nil
+	wad.Data = nil
+	wad.syncContents()
+
+	// The boot index must refer to one of our contents for the title to be launched.
+	bootable := false
+	for _, content := range b.contents {
+		index, err := wad.AddContent(content.data, content.id, content.contentType)
+		if err != nil {
+			return nil, err
+		}
+
+		bootable = bootable || index == b.bootIndex
+	}
+
+	if !bootable {
+		return nil, ErrInvalidBootIndex
+	}
+
+	return &wad, nil
+}
@@ -0,0 +1,32 @@
+package wadlib
+
+import "testing"
+
+func TestBuilderBootIndex(t *testing.T) {
+	for _, test := range []struct {
+		name      string
+		contents  int
+		bootIndex uint16
+		expected  error
+	}{
+		{"no contents", 0, 0, ErrNoContents},
+		{"boot index beyond contents", 2, 5, ErrInvalidBootIndex},
+		{"first content", 2, 0, nil},
+		{"last content", 2, 1, nil},
+	} {
+		b := NewWADBuilder().BootIndex(test.bootIndex)
+		for index := 0; index < test.contents; index++ {
+			b.AddContent([]byte{byte(index)}, uint32(index), TitleTypeNormal)
+		}
+
+		wad, err := b.Build()
+		if err != test.expected {
+			t.Errorf("%s: returned %v, expected %v", test.name, err, test.expected)
+			continue
+		}
+
+		if err == nil && wad.TMD.BootIndex != test.bootIndex {
+			t.Errorf("%s: TMD lists boot index %d, expected %d", test.name, wad.TMD.BootIndex, test.bootIndex)
+		}
+	}
+}
@@ -0,0 +1,374 @@
+package wadlib
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/rsa"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"strings"
+)
+
+var (
+	ErrUnknownSignatureType = errors.New("unknown signature type")
+	ErrUnknownPublicKeyType = errors.New("unknown public key type")
+	ErrCertificateNotFound  = errors.New("certificate does not exist within chain")
+	ErrNotRSAKey            = errors.New("certificate does not hold an RSA public key")
+)
+
+const (
+	// CAName is the name of the certificate authority certificate, issued by the root.
+	CAName = "CA00000001"
+	// TMDIssuer is the issuer listed within retail TMDs.
+	TMDIssuer = "Root-CA00000001-CP00000004"
+	// TicketIssuer is the issuer listed within retail tickets.
+	TicketIssuer = "Root-CA00000001-XS00000003"
+)
+
+// PublicKeyType describes the type of public key held within a certificate.
+type PublicKeyType uint32
+
+const (
+	PublicKeyRSA4096 PublicKeyType = 0x0
+	PublicKeyRSA2048 PublicKeyType = 0x1
+	PublicKeyECC     PublicKeyType = 0x2
+)
+
+// Certificate describes a single certificate within a certificate chain.
+// Within a WAD, the chain typically holds the CA, CP (TMD signer) and XS (ticket signer) certificates.
+type Certificate struct {
+	SignatureType SignatureType
+	// Signature is sized as appropriate for the signature type.
+	Signature []byte
+	Issuer    [64]byte
+	KeyType   PublicKeyType
+	Name      [64]byte
+	// KeyID is sometimes observed to be a date. Its purpose is otherwise unknown.
+	KeyID uint32
+	// Modulus and Exponent are only present for RSA public keys.
+	Modulus  []byte
+	Exponent uint32
+	// ECCPoint is only present for ECC public keys.
+	ECCPoint [60]byte
+}
+
+// certificateHeader describes the fixed-length values following a certificate's signature.
+type certificateHeader struct {
+	Issuer  [64]byte
+	KeyType PublicKeyType
+	Name    [64]byte
+	KeyID   uint32
+}
+
+// publicKeySizes returns the length of a public key with this type, alongside the
+// amount of padding following it. For RSA keys, the size does not include the exponent.
+func (k PublicKeyType) publicKeySizes() (int, int, error) {
+	switch k {
+	case PublicKeyRSA4096:
+		return 0x200, 0x34, nil
+	case PublicKeyRSA2048:
+		return 0x100, 0x34, nil
+	case PublicKeyECC:
+		return 0x3c, 0x3c, nil
+	default:
+		return 0, 0, ErrUnknownPublicKeyType
+	}
+}
+
+// nullTerminated returns the string held within the given bytes, up to its first null byte.
+func nullTerminated(source []byte) string {
+	end := bytes.IndexByte(source, 0x00)
+	if end == -1 {
+		end = len(source)
+	}
+
+	return string(source[:end])
+}
+
+// GetIssuer returns the issuer of this certificate, such as "Root-CA00000001".
+func (c *Certificate) GetIssuer() string {
+	return nullTerminated(c.Issuer[:])
+}
+
+// GetName returns the name of this certificate, such as "XS00000003".
+func (c *Certificate) GetName() string {
+	return nullTerminated(c.Name[:])
+}
+
+// FullName returns the issuer of this certificate alongside its name.
+// Tickets, TMDs and other certificates signed by this certificate
+// will list this within their issuer, such as "Root-CA00000001-XS00000003".
+func (c *Certificate) FullName() string {
+	return c.GetIssuer() + "-" + c.GetName()
+}
+
+// RSAPublicKey returns the RSA public key held within this certificate.
+func (c *Certificate) RSAPublicKey() (*rsa.PublicKey, error) {
+	if c.KeyType != PublicKeyRSA4096 && c.KeyType != PublicKeyRSA2048 {
+		return nil, ErrNotRSAKey
+	}
+
+	return &rsa.PublicKey{
+		N: new(big.Int).SetBytes(c.Modulus),
+		E: int(c.Exponent),
+	}, nil
+}
+
+// ParseCertificateChain separates the given certificate chain into its individual certificates.
+func ParseCertificateChain(source []byte) ([]Certificate, error) {
+	loadingBuf := bytes.NewReader(source)
+
+	var certs []Certificate
+	for loadingBuf.Len() > 0 {
+		offset := int64(len(source) - loadingBuf.Len())
+		cert, err := readCertificate(loadingBuf)
+		if err != nil {
+			return nil, newParseError(fmt.Sprintf("certificate %d", len(certs)), offset, err)
+		}
+
+		certs = append(certs, cert)
+	}
+
+	return certs, nil
+}
+
+// readCertificate reads a single certificate from the given reader.
+func readCertificate(r io.Reader) (Certificate, error) {
+	var cert Certificate
+	var err error
+	cert.SignatureType, cert.Signature, err = readSignature(r)
+	if err != nil {
+		return cert, err
+	}
+
+	var header certificateHeader
+	err = binary.Read(r, binary.BigEndian, &header)
+	if err != nil {
+		return cert, err
+	}
+
+	cert.Issuer = header.Issuer
+	cert.KeyType = header.KeyType
+	cert.Name = header.Name
+	cert.KeyID = header.KeyID
+
+	// Similarly, the public key's length differs per its type.
+	keySize, keyPadding, err := cert.KeyType.publicKeySizes()
+	if err != nil {
+		return cert, err
+	}
+
+	if cert.KeyType == PublicKeyECC {
+		_, err = io.ReadFull(r, cert.ECCPoint[:])
+	} else {
+		cert.Modulus = make([]byte, keySize)
+		_, err = io.ReadFull(r, cert.Modulus)
+		if err != nil {
+			return cert, err
+		}
+
+		err = binary.Read(r, binary.BigEndian, &cert.Exponent)
+	}
+	if err != nil {
+		return cert, err
+	}
+
+	_, err = io.ReadFull(r, make([]byte, keyPadding))
+	return cert, err
+}
+
+// GetBytes returns the bytes of this certificate as it would be stored within a certificate chain.
+func (c *Certificate) GetBytes() ([]byte, error) {
+	keySize, keyPadding, err := c.KeyType.publicKeySizes()
+	if err != nil {
+		return nil, err
+	}
+
+	var tmp bytes.Buffer
+	err = writeSignature(&tmp, c.SignatureType, c.Signature)
+	if err != nil {
+		return nil, err
+	}
+
+	err = binary.Write(&tmp, binary.BigEndian, certificateHeader{
+		Issuer:  c.Issuer,
+		KeyType: c.KeyType,
+		Name:    c.Name,
+		KeyID:   c.KeyID,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if c.KeyType == PublicKeyECC {
+		tmp.Write(c.ECCPoint[:])
+	} else {
+		if len(c.Modulus) != keySize {
+			return nil, fmt.Errorf("modulus should be %d bytes for key type %d", keySize, c.KeyType)
+		}
+
+		tmp.Write(c.Modulus)
+		err = binary.Write(&tmp, binary.BigEndian, c.Exponent)
+		if err != nil {
+			return nil, err
+		}
+	}
+	tmp.Write(make([]byte, keyPadding))
+
+	return tmp.Bytes(), nil
+}
+
+// getSignedBytes returns the portion of this certificate covered by its signature.
+// This begins at its issuer, immediately following the signature and its padding.
+func (c *Certificate) getSignedBytes() ([]byte, error) {
+	contents, err := c.GetBytes()
+	if err != nil {
+		return nil, err
+	}
+
+	signatureSize, signaturePadding, err := c.SignatureType.signatureSizes()
+	if err != nil {
+		return nil, err
+	}
+
+	return contents[4+signatureSize+signaturePadding:], nil
+}
+
+// BuildCertificateChain returns the bytes of the given certificates, in order, as a certificate chain.
+func BuildCertificateChain(certs []Certificate) ([]byte, error) {
+	var chain []byte
+	for _, cert := range certs {
+		contents, err := cert.GetBytes()
+		if err != nil {
+			return nil, err
+		}
+
+		chain = append(chain, contents...)
+	}
+
+	return chain, nil
+}
+
+// FindCertificate returns the certificate whose full name matches the given issuer.
+// For example, "Root-CA00000001-XS00000003" would return the XS00000003 certificate.
+func FindCertificate(certs []Certificate, issuer string) (*Certificate, error) {
+	for index := range certs {
+		if certs[index].FullName() == issuer {
+			return &certs[index], nil
+		}
+	}
+
+	return nil, ErrCertificateNotFound
+}
+
+// Sign signs this certificate with the given signer, listing the given issuer.
+// The signature type is determined by the size of the signer's RSA key.
+func (c *Certificate) Sign(signer crypto.Signer, issuer string) error {
+	err := setIssuer(&c.Issuer, issuer)
+	if err != nil {
+		return err
+	}
+
+	// The signature type determines the size of the signature,
+	// so it must be set prior to obtaining the signed contents.
+	c.SignatureType, err = signatureTypeFor(signer)
+	if err != nil {
+		return err
+	}
+
+	c.Signature, err = zeroSignature(c.SignatureType)
+	if err != nil {
+		return err
+	}
+
+	signed, err := c.getSignedBytes()
+	if err != nil {
+		return err
+	}
+
+	_, c.Signature, err = signContents(signer, signed)
+	return err
+}
+
+// newRSACertificate creates a certificate with the given name for the given RSA public key.
+// It must be signed prior to use.
+func newRSACertificate(name string, key crypto.PublicKey) (Certificate, error) {
+	cert := Certificate{}
+
+	rsaKey, ok := key.(*rsa.PublicKey)
+	if !ok {
+		return cert, ErrUnsupportedSigner
+	}
+
+	switch rsaKey.Size() {
+	case 0x200:
+		cert.KeyType = PublicKeyRSA4096
+	case 0x100:
+		cert.KeyType = PublicKeyRSA2048
+	default:
+		return cert, ErrUnsupportedSigner
+	}
+
+	err := setIssuer(&cert.Name, name)
+	if err != nil {
+		return cert, err
+	}
+
+	cert.Modulus = make([]byte, rsaKey.Size())
+	rsaKey.N.FillBytes(cert.Modulus)
+	cert.Exponent = uint32(rsaKey.E)
+	return cert, nil
+}
+
+// GenerateCertificateChain creates a certificate chain for the given keys, suitable for WAD.CertificateChain.
+// The CA certificate is signed by root, and the CP and XS certificates by ca.
+// Tickets signed by xs should use TicketIssuer, and TMDs signed by cp should use TMDIssuer.
+// The CP and XS keys must be RSA-2048, as is expected of retail tickets and TMDs.
+func GenerateCertificateChain(root crypto.Signer, ca crypto.Signer, cp crypto.Signer, xs crypto.Signer) ([]byte, error) {
+	caCert, err := newRSACertificate(CAName, ca.Public())
+	if err != nil {
+		return nil, err
+	}
+
+	err = caCert.Sign(root, rootIssuer)
+	if err != nil {
+		return nil, err
+	}
+
+	certs := []Certificate{caCert}
+	for _, issued := range []struct {
+		fullName string
+		signer   crypto.Signer
+	}{
+		{TMDIssuer, cp},
+		{TicketIssuer, xs},
+	} {
+		// Our issuer is everything prior to the final name.
+		separator := strings.LastIndex(issued.fullName, "-")
+		cert, err := newRSACertificate(issued.fullName[separator+1:], issued.signer.Public())
+		if err != nil {
+			return nil, err
+		}
+
+		if cert.KeyType != PublicKeyRSA2048 {
+			return nil, ErrUnsupportedSigner
+		}
+
+		err = cert.Sign(ca, issued.fullName[:separator])
+		if err != nil {
+			return nil, err
+		}
+
+		certs = append(certs, cert)
+	}
+
+	return BuildCertificateChain(certs)
+}
+
+// GetCertificates parses and returns the certificates within the certificate chain for the current WAD.
+func (w *WAD) GetCertificates() ([]Certificate, error) {
+	return ParseCertificateChain(w.CertificateChain)
+}
@@ -3,14 +3,46 @@ package wadlib
 // SignatureType allows specification of the type of signature to be parsed in a ticket.
 type SignatureType uint32
 
+// See https://git.io/JfJzH or, from acer_cloud_wifi_copy,
+// /sw_x/es_core/esc/core/base/include/esitypes.h#L74
+// Retail tickets and TMDs are signed with RSA 2048, while certificates
+// additionally observe RSA 4096 and ECC. Others may be emitted by external tools.
 const (
-	// SignatureRSA2048 is only one of several internal signature types.
-	// See https://git.io/JfJzH or, from acer_cloud_wifi_copy,
-	// /sw_x/es_core/esc/core/base/include/esitypes.h#L74
-	// However, only RSA 2048 is used in the Wii's title system.
+	// SignatureRSA4096 is used by the root certificate to sign the CA certificate.
+	SignatureRSA4096 SignatureType = 0x00010000
+	// SignatureRSA2048 is used by the CA certificate, and its issued certificates
+	// to sign tickets and TMDs in the Wii's title system.
 	SignatureRSA2048 SignatureType = 0x00010001
+	// SignatureECC is used by console-specific certificates, such as those for a device or its titles.
+	SignatureECC SignatureType = 0x00010002
+	// SignatureRSA4096SHA256 is SignatureRSA4096, hashed with SHA-256.
+	SignatureRSA4096SHA256 SignatureType = 0x00010003
+	// SignatureRSA2048SHA256 is SignatureRSA2048, hashed with SHA-256.
+	SignatureRSA2048SHA256 SignatureType = 0x00010004
+	// SignatureECCSHA256 is SignatureECC, hashed with SHA-256.
+	SignatureECCSHA256 SignatureType = 0x00010005
+	// SignatureHMACSHA1 is a 160-bit HMAC-SHA1 digest.
+	SignatureHMACSHA1 SignatureType = 0x00010006
 )
 
+// signatureSizes returns the length of a signature with this type, alongside the
+// amount of padding following it. Together with the signature type itself,
+// these are aligned to 0x40/64 bytes.
+func (s SignatureType) signatureSizes() (int, int, error) {
+	switch s {
+	case SignatureRSA4096, SignatureRSA4096SHA256:
+		return 0x200, 0x3c, nil
+	case SignatureRSA2048, SignatureRSA2048SHA256:
+		return 0x100, 0x3c, nil
+	case SignatureECC, SignatureECCSHA256:
+		return 0x3c, 0x40, nil
+	case SignatureHMACSHA1:
+		return 0x14, 0x28, nil
+	default:
+		return 0, 0, ErrUnknownSignatureType
+	}
+}
+
 type WADType uint32
 
 // WADType contains a list of WAD types to compare against.
@@ -1,60 +1,252 @@
 package wadlib
 
 import (
+	"context"
 	"errors"
+	"io"
 )
 
 var (
-	ErrInvalidIndex = errors.New("index does not exist within WAD")
+	// ErrInvalidIndex is the former name of ErrContentIndexOutOfRange, and is equal to it.
+	ErrInvalidIndex       = ErrContentIndexOutOfRange
+	ErrInvalidID          = errors.New("content ID does not exist within WAD")
+	ErrDuplicateContentID = errors.New("content ID already exists within WAD")
+	ErrTooManyContents    = errors.New("no further content indexes are available")
+	ErrRemoveBootContent  = errors.New("boot content cannot be removed")
 )
 
-// GetContent returns the data for the given index.
+// GetFile returns the WADFile for the content with the given index.
+// Content indexes are not necessarily sequential, so this may differ from its position within WAD.Data.
+func (w *WAD) GetFile(index uint16) (*WADFile, error) {
+	for position := range w.Data {
+		if w.Data[position].Record.Index == index {
+			return &w.Data[position], nil
+		}
+	}
+
+	return nil, ErrContentIndexOutOfRange
+}
+
+// GetFileByID returns the WADFile for the content with the given ID.
+func (w *WAD) GetFileByID(id uint32) (*WADFile, error) {
+	for position := range w.Data {
+		if w.Data[position].Record.ID == id {
+			return &w.Data[position], nil
+		}
+	}
+
+	return nil, ErrInvalidID
+}
+
+// GetFileAt returns the WADFile at the given position, in the order contents are listed within the TMD.
+func (w *WAD) GetFileAt(position int) (*WADFile, error) {
+	if position < 0 || position >= len(w.Data) {
+		return nil, ErrContentIndexOutOfRange
+	}
+
+	return &w.Data[position], nil
+}
+
+// GetContent returns the data for the content with the given index.
 func (w *WAD) GetContent(index int) ([]byte, error) {
 	// Ensure the index is valid.
-	if index > len(w.Data) {
-		return nil, ErrInvalidIndex
+	if index < 0 || index > 0xffff {
+		return nil, ErrContentIndexOutOfRange
+	}
+
+	file, err := w.GetFile(uint16(index))
+	if err != nil {
+		return nil, err
+	}
+
+	titleKey, err := w.Ticket.GetTitleKey()
+	if err != nil {
+		return nil, err
 	}
 
-	titleKey := w.Ticket.GetTitleKey()
-	return w.Data[index].DecryptData(titleKey)
+	return file.DecryptData(titleKey)
 }
 
-// UpdateContent updates the data at the given index with the given content.
+// GetContentByID returns the data for the content with the given ID.
+func (w *WAD) GetContentByID(id uint32) ([]byte, error) {
+	file, err := w.GetFileByID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	titleKey, err := w.Ticket.GetTitleKey()
+	if err != nil {
+		return nil, err
+	}
+
+	return file.DecryptData(titleKey)
+}
+
+// GetContentAt returns the data for the content at the given position,
+// in the order contents are listed within the TMD.
+func (w *WAD) GetContentAt(position int) ([]byte, error) {
+	file, err := w.GetFileAt(position)
+	if err != nil {
+		return nil, err
+	}
+
+	titleKey, err := w.Ticket.GetTitleKey()
+	if err != nil {
+		return nil, err
+	}
+
+	return file.DecryptData(titleKey)
+}
+
+// UpdateContent updates the data for the content with the given index with the given content.
 func (w *WAD) UpdateContent(index int, contents []byte) error {
 	// Ensure the index is valid.
-	if index > len(w.Data) {
-		return ErrInvalidIndex
+	if index < 0 || index > 0xffff {
+		return ErrContentIndexOutOfRange
+	}
+
+	file, err := w.GetFile(uint16(index))
+	if err != nil {
+		return err
 	}
 
-	titleKey := w.Ticket.GetTitleKey()
-	w.Data[index].UpdateData(contents, titleKey)
-	return nil
+	titleKey, err := w.Ticket.GetTitleKey()
+	if err != nil {
+		return err
+	}
+
+	file.UpdateData(contents, titleKey)
+
+	// Version 1 TMDs hash content records within their content info records.
+	return w.TMD.UpdateContentInfo()
+}
+
+// UpdateContentFromReader updates the data for the content with the given index with the content available
+// from the given reader, of the given size. Rather than being held in memory, the content
+// is encrypted on the fly as the WAD is written, and the reader must remain available until then.
+func (w *WAD) UpdateContentFromReader(index int, source io.ReaderAt, size int64) error {
+	// Ensure the index is valid.
+	if index < 0 || index > 0xffff {
+		return ErrContentIndexOutOfRange
+	}
+
+	file, err := w.GetFile(uint16(index))
+	if err != nil {
+		return err
+	}
+
+	titleKey, err := w.Ticket.GetTitleKey()
+	if err != nil {
+		return err
+	}
+
+	err = file.UpdateDataFromReader(source, size, titleKey)
+	if err != nil {
+		return err
+	}
+
+	return w.TMD.UpdateContentInfo()
 }
 
 // ChangeTitleKey updates the ticket to contain the given title key,
 // and re-encrypts all data to match.
+// Consider ChangeTitleKeyParallel for WADs with many contents.
 func (w *WAD) ChangeTitleKey(updatedKey [16]byte) error {
-	// Obtain the current title key.
-	oldTitleKey := w.Ticket.GetTitleKey()
-
-	// Save existing title data to a separate array.
-	titleData := make(map[int][]byte)
-	for index, data := range w.Data {
-		decrypted, err := data.DecryptData(oldTitleKey)
-		if err != nil {
-			return err
+	return w.ChangeTitleKeyParallel(context.Background(), updatedKey, 1)
+}
+
+// ChangeTitleID updates both the ticket and TMD to use the given title ID.
+// As the title ID is used as the IV to encrypt the title key, the title key is
+// decrypted under the previous title ID, and encrypted again under the new.
+// Contents are encrypted per their index, and are unaffected.
+func (w *WAD) ChangeTitleID(titleID TitleID) error {
+	titleKey, err := w.Ticket.GetTitleKey()
+	if err != nil {
+		return err
+	}
+
+	w.Ticket.TitleID = titleID
+	w.TMD.TitleID = titleID
+
+	return w.Ticket.UpdateTitleKey(titleKey)
+}
+
+// AddContent adds the given data as a new content with the given ID and type,
+// encrypted with the current title key. It is given the index following the highest
+// index present, which is returned. The TMD is updated to list the new content.
+func (w *WAD) AddContent(data []byte, id uint32, contentType ContentType) (uint16, error) {
+	var index uint16
+	for position, content := range w.TMD.Contents {
+		if content.ID == id {
+			return 0, ErrDuplicateContentID
 		}
 
-		titleData[index] = decrypted
+		if position == 0 || content.Index >= index {
+			if content.Index == 0xffff {
+				return 0, ErrTooManyContents
+			}
+			index = content.Index + 1
+		}
 	}
 
-	// Update our title key.
-	w.Ticket.UpdateTitleKey(updatedKey)
+	// Ensure our title key is available before altering the TMD.
+	titleKey, err := w.Ticket.GetTitleKey()
+	if err != nil {
+		return 0, err
+	}
+
+	w.TMD.Contents = append(w.TMD.Contents, ContentRecord{
+		ID:    id,
+		Index: index,
+		Type:  contentType,
+	})
+	w.Data = append(w.Data, WADFile{})
+	w.syncContents()
+
+	w.Data[len(w.Data)-1].UpdateData(data, titleKey)
+
+	err = w.TMD.UpdateContentInfo()
+	if err != nil {
+		return 0, err
+	}
+	return index, nil
+}
+
+// RemoveContent removes the content with the given index, alongside its record within the TMD.
+// Indexes of remaining contents are left as-is, as their data is encrypted per their index.
+// The boot content, as specified by the TMD's BootIndex, cannot be removed.
+func (w *WAD) RemoveContent(index int) error {
+	// Ensure the index is valid.
+	if index < 0 || index > 0xffff {
+		return ErrContentIndexOutOfRange
+	}
+
+	if uint16(index) == w.TMD.BootIndex {
+		return ErrRemoveBootContent
+	}
+
+	for position, content := range w.TMD.Contents {
+		if content.Index != uint16(index) {
+			continue
+		}
+
+		w.TMD.Contents = append(w.TMD.Contents[:position], w.TMD.Contents[position+1:]...)
+		w.Data = append(w.Data[:position], w.Data[position+1:]...)
+		w.syncContents()
+		return w.TMD.UpdateContentInfo()
+	}
+
+	return ErrContentIndexOutOfRange
+}
 
-	// Encrypt our separate title data.
-	for index, data := range titleData {
-		w.Data[index].UpdateData(data, updatedKey)
+// syncContents ensures each WADFile references its record within the TMD,
+// and that the TMD's count of contents is accurate.
+// This is necessary after the TMD's records have been reallocated or reordered.
+// WAD.Data is expected to be in the same order as the TMD's records.
+func (w *WAD) syncContents() {
+	for position := range w.Data {
+		w.Data[position].Record = &w.TMD.Contents[position]
 	}
 
-	return nil
+	w.TMD.NumberOfContents = uint16(len(w.TMD.Contents))
 }
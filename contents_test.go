@@ -0,0 +1,258 @@
+package wadlib
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"encoding/binary"
+	"errors"
+	"testing"
+)
+
+func TestContentIndexOutOfRange(t *testing.T) {
+	wad := testWAD(t, testWADContents()...)
+
+	for _, test := range []struct {
+		name string
+		err  error
+	}{
+		{"GetContent", func() error { _, err := wad.GetContent(3); return err }()},
+		{"GetContent beyond uint16", func() error { _, err := wad.GetContent(0x10000); return err }()},
+		{"GetFile", func() error { _, err := wad.GetFile(3); return err }()},
+		{"GetFileAt", func() error { _, err := wad.GetFileAt(-1); return err }()},
+		{"UpdateContent", wad.UpdateContent(3, []byte{})},
+		{"RemoveContent", wad.RemoveContent(-1)},
+	} {
+		if !errors.Is(test.err, ErrContentIndexOutOfRange) {
+			t.Errorf("%s: returned %v, expected ErrContentIndexOutOfRange", test.name, test.err)
+		}
+
+		// The former name must continue to match.
+		if !errors.Is(test.err, ErrInvalidIndex) {
+			t.Errorf("%s: returned %v, which does not match ErrInvalidIndex", test.name, test.err)
+		}
+	}
+}
+
+// testSparseWAD returns the bytes of a WAD listing contents with the indexes 0, 8 and 3, in that order.
+func testSparseWAD(t *testing.T) ([]byte, [][]byte) {
+	t.Helper()
+
+	contents := testWADContents()
+	wad := testWAD(t, contents...)
+
+	// Contents are encrypted per their index, so each must be updated once re-indexed.
+	wad.TMD.Contents[1].Index = 8
+	wad.TMD.Contents[2].Index = 3
+	for position, index := range []int{8, 3} {
+		if err := wad.UpdateContent(index, contents[position+1]); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	source, err := wad.GetWAD(WADTypeCommon)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return source, contents
+}
+
+func TestSparseContentIndexes(t *testing.T) {
+	source, contents := testSparseWAD(t)
+
+	eager, err := LoadWAD(source)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	lazy, err := LoadWADFromReader(bytes.NewReader(source), int64(len(source)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, wad := range []*WAD{eager, lazy} {
+		for position, index := range []int{0, 8, 3} {
+			byIndex, err := wad.GetContent(index)
+			if err != nil {
+				t.Fatalf("index %d: %v", index, err)
+			}
+
+			byID, err := wad.GetContentByID(uint32(position))
+			if err != nil {
+				t.Fatalf("ID %d: %v", position, err)
+			}
+
+			byPosition, err := wad.GetContentAt(position)
+			if err != nil {
+				t.Fatalf("position %d: %v", position, err)
+			}
+
+			for _, data := range [][]byte{byIndex, byID, byPosition} {
+				if !bytes.Equal(data, contents[position]) {
+					t.Errorf("content with index %d at position %d differs", index, position)
+				}
+			}
+		}
+
+		// Indexes between those present do not exist.
+		if _, err = wad.GetContent(1); !errors.Is(err, ErrContentIndexOutOfRange) {
+			t.Errorf("index 1 returned %v, expected ErrContentIndexOutOfRange", err)
+		}
+	}
+}
+
+// testContentsConsistent ensures the TMD's records, count of contents and WAD.Data are in step.
+func testContentsConsistent(t *testing.T, wad *WAD) {
+	t.Helper()
+
+	if int(wad.TMD.NumberOfContents) != len(wad.TMD.Contents) || len(wad.Data) != len(wad.TMD.Contents) {
+		t.Fatalf("TMD lists %d contents with %d records, while WAD holds %d", wad.TMD.NumberOfContents, len(wad.TMD.Contents), len(wad.Data))
+	}
+
+	for position := range wad.Data {
+		if wad.Data[position].Record != &wad.TMD.Contents[position] {
+			t.Errorf("content at position %d does not reference its record", position)
+		}
+	}
+}
+
+func TestAddRemoveContent(t *testing.T) {
+	source, contents := testSparseWAD(t)
+	wad, err := LoadWAD(source)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The highest index present is 8, regardless of position.
+	added := []byte("added content")
+	index, err := wad.AddContent(added, 0x10, TitleTypeShared)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if index != 9 {
+		t.Errorf("added content has index %d, expected 9", index)
+	}
+	testContentsConsistent(t, wad)
+
+	if _, err = wad.AddContent(added, 0x10, TitleTypeNormal); err != ErrDuplicateContentID {
+		t.Errorf("adding a duplicate ID returned %v, expected ErrDuplicateContentID", err)
+	}
+
+	if err = wad.RemoveContent(int(wad.TMD.BootIndex)); err != ErrRemoveBootContent {
+		t.Errorf("removing the boot content returned %v, expected ErrRemoveBootContent", err)
+	}
+
+	if err = wad.RemoveContent(8); err != nil {
+		t.Fatal(err)
+	}
+	testContentsConsistent(t, wad)
+
+	written, err := wad.GetWAD(WADTypeCommon)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	reloaded, err := LoadWAD(written)
+	if err != nil {
+		t.Fatal(err)
+	}
+	testContentsConsistent(t, reloaded)
+
+	for _, test := range []struct {
+		index    int
+		id       uint32
+		expected []byte
+	}{
+		{0, 0, contents[0]},
+		{3, 2, contents[2]},
+		{9, 0x10, added},
+	} {
+		data, err := reloaded.GetContent(test.index)
+		if err != nil {
+			t.Fatalf("index %d: %v", test.index, err)
+		}
+
+		if !bytes.Equal(data, test.expected) {
+			t.Errorf("content with index %d differs", test.index)
+		}
+
+		file, err := reloaded.GetFile(uint16(test.index))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if file.Record.ID != test.id {
+			t.Errorf("content with index %d has ID %d, expected %d", test.index, file.Record.ID, test.id)
+		}
+	}
+
+	if _, err = reloaded.GetContent(8); !errors.Is(err, ErrContentIndexOutOfRange) {
+		t.Errorf("removed index 8 returned %v, expected ErrContentIndexOutOfRange", err)
+	}
+
+	shared, err := reloaded.GetFile(9)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if shared.Record.Type != TitleTypeShared {
+		t.Errorf("added content has type %#x, expected %#x", shared.Record.Type, TitleTypeShared)
+	}
+}
+
+func TestChangeTitleID(t *testing.T) {
+	contents := testWADContents()
+	wad := testWAD(t, contents...)
+
+	updated := NewTitleID(0x00010001, 0x48424242)
+	if err := wad.ChangeTitleID(updated); err != nil {
+		t.Fatal(err)
+	}
+
+	source, err := wad.GetWAD(WADTypeCommon)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	reloaded, err := LoadWAD(source)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if reloaded.Ticket.TitleID != updated || reloaded.TMD.TitleID != updated {
+		t.Errorf("ticket has title ID %s and TMD %s, expected %s", reloaded.Ticket.TitleID, reloaded.TMD.TitleID, updated)
+	}
+
+	// The title key should be encrypted with the common key, using the new title ID as its IV.
+	block, err := aes.NewCipher(CommonKey[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	iv := make([]byte, aes.BlockSize)
+	binary.BigEndian.PutUint64(iv, uint64(updated))
+
+	var expected [16]byte
+	cipher.NewCBCEncrypter(block, iv).CryptBlocks(expected[:], testTitleKey[:])
+	if reloaded.Ticket.TitleKey != expected {
+		t.Errorf("encrypted title key is %x, expected %x", reloaded.Ticket.TitleKey, expected)
+	}
+
+	titleKey, err := reloaded.Ticket.GetTitleKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if titleKey != testTitleKey {
+		t.Errorf("title key is %x, expected %x", titleKey, testTitleKey)
+	}
+
+	for position, content := range contents {
+		data, err := reloaded.GetContentAt(position)
+		if err != nil {
+			t.Fatalf("position %d: %v", position, err)
+		}
+
+		if !bytes.Equal(data, content) {
+			t.Errorf("content at position %d differs", position)
+		}
+	}
+}
@@ -0,0 +1,300 @@
+package wadlib
+
+import (
+	"encoding/hex"
+	"errors"
+	"math/bits"
+)
+
+var (
+	ErrInvalidECCPoint      = errors.New("ECC public key does not lie on the curve")
+	ErrInvalidECCPrivateKey = errors.New("ECC private key is invalid")
+)
+
+// The Wii uses sect233r1 for its ECC keys, as used by console certificates and personalized tickets.
+// No implementation of this curve exists within the standard library, so we implement it here.
+// As it is only used to unwrap title keys, no effort has been made to run in constant time.
+//
+// Elements of GF(2^233) are stored as four 64-bit words, least significant word first.
+// The field's reduction polynomial is x^233 + x^74 + 1.
+type gf233 [4]uint64
+
+// eccElementSize is the size of a single serialized field element.
+// Points are serialized as both coordinates, for a total of 60 bytes.
+const eccElementSize = 30
+
+// eccPoint is a point on the curve in affine coordinates.
+// The point at infinity is represented by infinity being set.
+type eccPoint struct {
+	x, y     gf233
+	infinity bool
+}
+
+var (
+	// eccB is the curve's b coefficient. Its a coefficient is 1.
+	eccB = gf233FromHex("0066647ede6c332c7f8c0923bb58213b333b20e9ce4281fe115f7d8f90ad")
+	// eccG is the curve's base point.
+	eccG = eccPoint{
+		x: gf233FromHex("00fac9dfcbac8313bb2139f1bb755fef65bc391f8b36f8f8eb7371fd558b"),
+		y: gf233FromHex("01006a08a41903350678e58528bebf8a0beff867a7ca36716f7e01f81052"),
+	}
+	// eccOrder is the order of the base point, as serialized.
+	eccOrder = gf233FromHex("01000000000000000000000000000013e974e72f8a6922031d2603cfe0d7")
+)
+
+// gf233FromHex parses a hexadecimal constant. It is only used for the above.
+func gf233FromHex(source string) gf233 {
+	serialized, err := hex.DecodeString(source)
+	if err != nil {
+		panic(err)
+	}
+
+	return gf233FromBytes(serialized)
+}
+
+// gf233FromBytes reads a 30-byte big-endian element.
+// Bits beyond the field's 233 bits are discarded.
+func gf233FromBytes(source []byte) gf233 {
+	var e gf233
+	for i, b := range source[:eccElementSize] {
+		bit := uint((eccElementSize - 1 - i) * 8)
+		e[bit/64] |= uint64(b) << (bit % 64)
+	}
+
+	e[3] &= 1<<(233-192) - 1
+	return e
+}
+
+// bytes returns this element as 30 big-endian bytes.
+func (e gf233) bytes() []byte {
+	serialized := make([]byte, eccElementSize)
+	for i := range serialized {
+		bit := uint((eccElementSize - 1 - i) * 8)
+		serialized[i] = byte(e[bit/64] >> (bit % 64))
+	}
+
+	return serialized
+}
+
+func (e gf233) isZero() bool {
+	return e[0]|e[1]|e[2]|e[3] == 0
+}
+
+func (e gf233) bit(n uint) bool {
+	return e[n/64]>>(n%64)&1 == 1
+}
+
+func (e gf233) add(o gf233) gf233 {
+	return gf233{e[0] ^ o[0], e[1] ^ o[1], e[2] ^ o[2], e[3] ^ o[3]}
+}
+
+// mulX multiplies this element by x, reducing as necessary.
+func (e gf233) mulX() gf233 {
+	var r gf233
+	var carry uint64
+	for i := range e {
+		r[i] = e[i]<<1 | carry
+		carry = e[i] >> 63
+	}
+
+	// x^233 is equivalent to x^74 + 1.
+	if r.bit(233) {
+		r[3] &^= 1 << (233 - 192)
+		r[74/64] ^= 1 << (74 % 64)
+		r[0] ^= 1
+	}
+	return r
+}
+
+func (e gf233) mul(o gf233) gf233 {
+	var r gf233
+	for n := 232; n >= 0; n-- {
+		r = r.mulX()
+		if o.bit(uint(n)) {
+			r = r.add(e)
+		}
+	}
+
+	return r
+}
+
+// degree returns the degree of this element as a polynomial, or -1 for zero.
+func (e gf233) degree() int {
+	for i := len(e) - 1; i >= 0; i-- {
+		if e[i] != 0 {
+			return i*64 + bits.Len64(e[i]) - 1
+		}
+	}
+
+	return -1
+}
+
+// shiftLeft multiplies this element by x^n without reducing.
+// The caller must ensure the result fits within 256 bits.
+func (e gf233) shiftLeft(n int) gf233 {
+	var r gf233
+	words, offset := n/64, uint(n%64)
+	for i := len(e) - 1; i >= words; i-- {
+		r[i] = e[i-words] << offset
+		if offset != 0 && i-words > 0 {
+			r[i] |= e[i-words-1] >> (64 - offset)
+		}
+	}
+
+	return r
+}
+
+// inverse returns the multiplicative inverse of this element
+// via the extended Euclidean algorithm for binary polynomials.
+func (e gf233) inverse() gf233 {
+	// The reduction polynomial, x^233 + x^74 + 1.
+	u, v := e, gf233{1, 1 << (74 % 64), 0, 1 << (233 - 192)}
+	g1, g2 := gf233{1}, gf233{}
+
+	for u.degree() > 0 {
+		j := u.degree() - v.degree()
+		if j < 0 {
+			u, v = v, u
+			g1, g2 = g2, g1
+			j = -j
+		}
+
+		u = u.add(v.shiftLeft(j))
+		g1 = g1.add(g2.shiftLeft(j))
+	}
+
+	return g1
+}
+
+func (e gf233) div(o gf233) gf233 {
+	return e.mul(o.inverse())
+}
+
+// eccPointFromBytes reads a 60-byte serialized point, ensuring it lies on the curve.
+func eccPointFromBytes(source []byte) (eccPoint, error) {
+	p := eccPoint{
+		x: gf233FromBytes(source[:eccElementSize]),
+		y: gf233FromBytes(source[eccElementSize : eccElementSize*2]),
+	}
+
+	if !p.onCurve() {
+		return eccPoint{}, ErrInvalidECCPoint
+	}
+	return p, nil
+}
+
+// bytes returns this point as 60 bytes. The point at infinity is all zeroes.
+func (p eccPoint) bytes() []byte {
+	return append(p.x.bytes(), p.y.bytes()...)
+}
+
+// onCurve determines whether y^2 + xy = x^3 + x^2 + b holds for this point.
+func (p eccPoint) onCurve() bool {
+	if p.infinity {
+		return true
+	}
+
+	x2 := p.x.mul(p.x)
+	left := p.y.mul(p.y).add(p.x.mul(p.y))
+	right := x2.mul(p.x).add(x2).add(eccB)
+	return left == right
+}
+
+func (p eccPoint) double() eccPoint {
+	if p.infinity || p.x.isZero() {
+		return eccPoint{infinity: true}
+	}
+
+	// λ = x + y/x
+	// x' = λ^2 + λ + a
+	// y' = x^2 + (λ + 1)x'
+	lambda := p.x.add(p.y.div(p.x))
+	x := lambda.mul(lambda).add(lambda).add(gf233{1})
+	y := p.x.mul(p.x).add(lambda.add(gf233{1}).mul(x))
+	return eccPoint{x: x, y: y}
+}
+
+func (p eccPoint) add(q eccPoint) eccPoint {
+	switch {
+	case p.infinity:
+		return q
+	case q.infinity:
+		return p
+	case p.x == q.x && p.y == q.y:
+		return p.double()
+	case p.x == q.x:
+		// q is the negation of p, (x, x + y).
+		return eccPoint{infinity: true}
+	}
+
+	// λ = (y1 + y2) / (x1 + x2)
+	// x3 = λ^2 + λ + x1 + x2 + a
+	// y3 = λ(x1 + x3) + x3 + y1
+	lambda := p.y.add(q.y).div(p.x.add(q.x))
+	x := lambda.mul(lambda).add(lambda).add(p.x).add(q.x).add(gf233{1})
+	y := lambda.mul(p.x.add(x)).add(x).add(p.y)
+	return eccPoint{x: x, y: y}
+}
+
+// mul multiplies this point by the given 30-byte big-endian scalar.
+func (p eccPoint) mul(scalar []byte) eccPoint {
+	r := eccPoint{infinity: true}
+	for _, b := range scalar {
+		for bit := 7; bit >= 0; bit-- {
+			r = r.double()
+			if b>>uint(bit)&1 == 1 {
+				r = r.add(p)
+			}
+		}
+	}
+
+	return r
+}
+
+// eccSharedSecret returns the x coordinate of the point shared between
+// the given private key and the other party's public key.
+func eccSharedSecret(privateKey [30]byte, publicKey [60]byte) ([]byte, error) {
+	if !validPrivateKey(privateKey) {
+		return nil, ErrInvalidECCPrivateKey
+	}
+
+	point, err := eccPointFromBytes(publicKey[:])
+	if err != nil {
+		return nil, err
+	}
+
+	shared := point.mul(privateKey[:])
+	if shared.infinity {
+		return nil, ErrInvalidECCPoint
+	}
+	return shared.x.bytes(), nil
+}
+
+// ECCPublicKey returns the public key for the given sect233r1 private key,
+// such as a console's private key. It is serialized as both coordinates, for 60 bytes.
+func ECCPublicKey(privateKey [30]byte) ([60]byte, error) {
+	var publicKey [60]byte
+	if !validPrivateKey(privateKey) {
+		return publicKey, ErrInvalidECCPrivateKey
+	}
+
+	copy(publicKey[:], eccG.mul(privateKey[:]).bytes())
+	return publicKey, nil
+}
+
+// validPrivateKey determines whether the given private key lies within [1, n).
+func validPrivateKey(privateKey [30]byte) bool {
+	var zero [30]byte
+	return privateKey != zero && lessThan(privateKey[:], eccOrder.bytes())
+}
+
+// lessThan compares two big-endian values of the same length.
+func lessThan(a []byte, b []byte) bool {
+	for i := range a {
+		if a[i] != b[i] {
+			return a[i] < b[i]
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,202 @@
+package wadlib
+
+import (
+	"bytes"
+	"encoding/hex"
+	"testing"
+)
+
+// The following vectors were computed independently of this package.
+const (
+	// testScalar is 0x01, 0x02 ... 0x1e.
+	testScalar    = "0102030405060708090a0b0c0d0e0f101112131415161718191a1b1c1d1e"
+	testScalarGX  = "00783d1c66477306349a1b62c75541fcf98bbc4e8880742693d9fa74d0ab"
+	testScalarGY  = "001129334961ae6269aee84a91e60a560267686b1b3397e7bfb27afdf76d"
+	testConsole   = "00c0ffee1111111111111111111111111111111111111111111111111111"
+	testConsolePK = "01d090c4389fe807fe90446e1727281b9d6a05b004c804229a0fb5fdcaf1" +
+		"0046c9811defea67b0a7fb8cba5517f5d4dd230b284a26696a75750bcce5"
+	testServer   = "00772a2a2a2a2a2a2a2a2a2a2a2a2a2a2a2a2a2a2a2a2a2a2a2a2a2a2a2a"
+	testServerPK = "0147ee49ec099f5cc4682036a7ec3c106902478d829cfb60674cc3a93b25" +
+		"00985e62a1bf71e8e7747711b4c8284d9c1a77b75a07a2a3024285730ffb"
+	testShared = "00e7776c4836019146b57eb5206a6aef0e90786f52a1e765995cd13006d5"
+)
+
+func mustDecodeHex(t *testing.T, source string) []byte {
+	t.Helper()
+
+	decoded, err := hex.DecodeString(source)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return decoded
+}
+
+func testPrivateKey(t *testing.T, source string) [30]byte {
+	var key [30]byte
+	copy(key[:], mustDecodeHex(t, source))
+	return key
+}
+
+func testPublicKey(t *testing.T, source string) [60]byte {
+	var key [60]byte
+	copy(key[:], mustDecodeHex(t, source))
+	return key
+}
+
+func TestECCBasePoint(t *testing.T) {
+	if !eccG.onCurve() {
+		t.Fatal("base point does not lie on the curve")
+	}
+
+	if !eccG.mul(eccOrder.bytes()).infinity {
+		t.Error("n·G is not the point at infinity")
+	}
+
+	// (n-1)·G should be -G, being (x, x+y) on binary curves.
+	orderLessOne := eccOrder.bytes()
+	orderLessOne[len(orderLessOne)-1]--
+	negated := eccG.mul(orderLessOne)
+	if negated.infinity || negated.x != eccG.x || negated.y != eccG.x.add(eccG.y) {
+		t.Error("(n-1)·G is not -G")
+	}
+}
+
+func TestECCScalarMultiplication(t *testing.T) {
+	point := eccG.mul(mustDecodeHex(t, testScalar))
+	if !point.onCurve() {
+		t.Fatal("k·G does not lie on the curve")
+	}
+
+	expected := append(mustDecodeHex(t, testScalarGX), mustDecodeHex(t, testScalarGY)...)
+	if !bytes.Equal(point.bytes(), expected) {
+		t.Errorf("k·G is %x, expected %x", point.bytes(), expected)
+	}
+}
+
+func TestECCInverse(t *testing.T) {
+	one := gf233{1}
+	for _, element := range []gf233{one, eccG.x, eccG.y, eccB} {
+		if element.mul(element.inverse()) != one {
+			t.Errorf("%x multiplied by its inverse is not 1", element.bytes())
+		}
+	}
+}
+
+func TestECCPublicKey(t *testing.T) {
+	for _, test := range []struct {
+		private string
+		public  string
+	}{
+		{testConsole, testConsolePK},
+		{testServer, testServerPK},
+	} {
+		public, err := ECCPublicKey(testPrivateKey(t, test.private))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if public != testPublicKey(t, test.public) {
+			t.Errorf("public key for %s is %x, expected %s", test.private, public, test.public)
+		}
+	}
+
+	_, err := ECCPublicKey([30]byte{})
+	if err != ErrInvalidECCPrivateKey {
+		t.Errorf("zero private key returned %v, expected ErrInvalidECCPrivateKey", err)
+	}
+}
+
+func TestECCSharedSecret(t *testing.T) {
+	consoleShared, err := eccSharedSecret(testPrivateKey(t, testConsole), testPublicKey(t, testServerPK))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	serverShared, err := eccSharedSecret(testPrivateKey(t, testServer), testPublicKey(t, testConsolePK))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(consoleShared, serverShared) {
+		t.Error("shared secrets differ between both parties")
+	}
+
+	if !bytes.Equal(consoleShared, mustDecodeHex(t, testShared)) {
+		t.Errorf("shared secret is %x, expected %s", consoleShared, testShared)
+	}
+
+	// A point not on the curve must be rejected.
+	invalid := testPublicKey(t, testServerPK)
+	invalid[59] ^= 1
+	_, err = eccSharedSecret(testPrivateKey(t, testConsole), invalid)
+	if err != ErrInvalidECCPoint {
+		t.Errorf("invalid point returned %v, expected ErrInvalidECCPoint", err)
+	}
+}
+
+// testPersonalizedTicket returns a ticket bound to testConsole, holding the title key 00112233...eeff.
+func testPersonalizedTicket(t *testing.T) *Ticket {
+	wad := WAD{}
+	err := wad.LoadTicket(TicketTemplate)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ticket := wad.Ticket
+	ticket.KeyType = KeyTypeCommon
+	ticket.TitleID = NewTitleID(0x00010001, 0x48414141)
+	ticket.TicketID = 0x0102030405060708
+	ticket.ConsoleID = 0x0badcafe
+	ticket.ECDHData = testPublicKey(t, testServerPK)
+	copy(ticket.TitleKey[:], mustDecodeHex(t, "bfda50c841e86bae03e7b206ac14a792"))
+	return &ticket
+}
+
+func TestGetPersonalizedTitleKey(t *testing.T) {
+	ticket := testPersonalizedTicket(t)
+	if !ticket.IsPersonalized() {
+		t.Fatal("ticket is not personalized")
+	}
+
+	titleKey, err := ticket.GetPersonalizedTitleKey(testPrivateKey(t, testConsole))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := mustDecodeHex(t, "00112233445566778899aabbccddeeff")
+	if !bytes.Equal(titleKey[:], expected) {
+		t.Errorf("title key is %x, expected %x", titleKey, expected)
+	}
+}
+
+func TestDepersonalize(t *testing.T) {
+	ticket := testPersonalizedTicket(t)
+	err := ticket.Depersonalize(testPrivateKey(t, testConsole))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if ticket.IsPersonalized() || ticket.ConsoleID != 0 || ticket.ECDHData != [60]byte{} {
+		t.Error("ticket remains bound to a console")
+	}
+
+	// The title key should now only be encrypted with the common key.
+	expectedEncrypted := mustDecodeHex(t, "b890cbb7fdec48e90e104ccaddc91ddb")
+	if !bytes.Equal(ticket.TitleKey[:], expectedEncrypted) {
+		t.Errorf("encrypted title key is %x, expected %x", ticket.TitleKey, expectedEncrypted)
+	}
+
+	titleKey, err := ticket.GetTitleKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := mustDecodeHex(t, "00112233445566778899aabbccddeeff")
+	if !bytes.Equal(titleKey[:], expected) {
+		t.Errorf("title key is %x, expected %x", titleKey, expected)
+	}
+
+	if err = ticket.Depersonalize(testPrivateKey(t, testConsole)); err != ErrNotPersonalized {
+		t.Errorf("depersonalizing again returned %v, expected ErrNotPersonalized", err)
+	}
+}
@@ -0,0 +1,66 @@
+package wadlib
+
+import (
+	"errors"
+	"fmt"
+	"io"
+)
+
+var (
+	ErrTruncated             = errors.New("data is truncated")
+	ErrInvalidHeaderSize     = errors.New("header should be 32 bytes in default Nintendo configuration")
+	ErrDuplicateContentIndex = errors.New("content index is listed more than once")
+	ErrSectionOverflow       = errors.New("section extends beyond the data available")
+	// ErrContentIndexOutOfRange is returned when no content has the given index,
+	// or the index cannot be held by a content at all.
+	ErrContentIndexOutOfRange = errors.New("content index does not exist within WAD")
+)
+
+// ParseError describes an error encountered while parsing, alongside where it was encountered.
+// Use errors.Is to compare against the underlying error, such as ErrTruncated.
+type ParseError struct {
+	// Section describes what was being parsed, such as "ticket" or "content 1".
+	Section string
+	// Offset is where the section, or value within, begins.
+	// When returned from LoadWAD or LoadWADFromReader, this is relative to the start of the WAD.
+	// Otherwise, it is relative to the start of the data passed.
+	Offset int64
+	Err    error
+}
+
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("%s at offset 0x%x: %v", e.Section, e.Offset, e.Err)
+}
+
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
+
+// newParseError returns a ParseError for the given section and offset.
+// Errors from a read ending early are reported as ErrTruncated.
+func newParseError(section string, offset int64, err error) error {
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		err = ErrTruncated
+	}
+
+	return &ParseError{
+		Section: section,
+		Offset:  offset,
+		Err:     err,
+	}
+}
+
+// withOffset adjusts the offset of the given error to be relative to a section beginning at base.
+// Errors that are not a ParseError are wrapped as such, describing the given section.
+func withOffset(section string, base int64, err error) error {
+	var parseErr *ParseError
+	if errors.As(err, &parseErr) {
+		return &ParseError{
+			Section: parseErr.Section,
+			Offset:  base + parseErr.Offset,
+			Err:     parseErr.Err,
+		}
+	}
+
+	return newParseError(section, base, err)
+}
@@ -5,67 +5,172 @@ import (
 	"crypto/aes"
 	"crypto/cipher"
 	"crypto/sha1"
+	"crypto/sha256"
 	"encoding/binary"
 	"errors"
 	"fmt"
+	"io"
 )
 
 // WADFile represents a file within a WAD.
 // RawData should always be the encrypted data ready to be stored within a WAD.
+// If loaded via LoadWADFromReader, RawData is empty and data is instead read
+// from its source when necessary. Use GetRawData to handle both cases.
 type WADFile struct {
 	Record  *ContentRecord
 	RawData []byte
+	// source holds the encrypted data for this content when loaded lazily.
+	source *io.SectionReader
+	// plaintext holds the decrypted data for this content when it is to be encrypted on the fly.
+	// It is encrypted with titleKey as it is written.
+	plaintext *io.SectionReader
+	titleKey  [16]byte
+}
+
+// encryptionChunkSize is the amount of data encrypted at once when encrypting on the fly.
+// It must be a multiple of the AES block size.
+const encryptionChunkSize = 0x10000
+
+// getEncryptedSize returns the size of a content's encrypted data.
+// Not all contents meet the expected 16-byte boundary, so this is padded as such.
+func getEncryptedSize(content ContentRecord) uint32 {
+	// It's okay to cast this from a uint64 as the WAD file format
+	// cannot exceed the maximum uint32 value within the data section.
+	paddedSize := uint32(content.Size)
+	leftover := paddedSize % 16
+	if leftover != 0 {
+		paddedSize += 16 - leftover
+	}
+
+	return paddedSize
 }
 
 // LoadDataSection loads the binary data from a WAD and parses it as specified within the TMD.
 func (w *WAD) LoadDataSection(data []byte) error {
-	// Each content within the data section is aligned to a 0x40/64-byte boundary.
-	r := readable{
-		data: data,
+	offsets, err := w.layoutDataSection(int64(len(data)))
+	if err != nil {
+		return err
 	}
-	contents := w.TMD.Contents
-
-	// TODO: We naively assume that the index will be accurately indexed from 0.
-	// All observed Nintendo files follow this, but external tools may not follow this format.
-	// We should most likely apply max index validation and sort,
-	// otherwise data will read out of order in these cases.
 
 	// All data contents will be the same amount as the number of contents per TMD.
-	w.Data = make([]WADFile, len(contents))
+	w.Data = make([]WADFile, len(w.TMD.Contents))
 	for idx, content := range w.TMD.Contents {
-		// It's okay to cast this from a uint64 as the WAD file format
-		// cannot exceed the maximum uint32 value within the data section.
 		// We read aligned to 16 bytes as the encrypted data is stored with padding.
-		// Not all contents meet the expected 16-byte boundary.
-		paddedSize := uint32(content.Size)
-		leftover := paddedSize % 16
-		if leftover != 0 {
-			paddedSize += 16 - leftover
+		w.Data[idx] = WADFile{
+			Record:  &w.TMD.Contents[idx],
+			RawData: data[offsets[idx] : offsets[idx]+int64(getEncryptedSize(content))],
 		}
+	}
 
-		// Read the padded amount as aligned to 64 bytes.
-		encryptedData := r.getRange(paddedSize)
+	return nil
+}
 
-		file := WADFile{
-			Record:  &w.TMD.Contents[idx],
-			RawData: encryptedData,
-		}
+// loadDataSectionFromReader prepares contents to be read lazily from the given data section.
+func (w *WAD) loadDataSectionFromReader(data *io.SectionReader) error {
+	offsets, err := w.layoutDataSection(data.Size())
+	if err != nil {
+		return err
+	}
 
-		w.Data[content.Index] = file
+	w.Data = make([]WADFile, len(w.TMD.Contents))
+	for idx, content := range w.TMD.Contents {
+		w.Data[idx] = WADFile{
+			Record: &w.TMD.Contents[idx],
+			source: io.NewSectionReader(data, offsets[idx], int64(getEncryptedSize(content))),
+		}
 	}
 
 	return nil
 }
 
+// layoutDataSection returns the offset of each content listed within the TMD,
+// ensuring all fit within a data section of the given size.
+func (w *WAD) layoutDataSection(size int64) ([]int64, error) {
+	// Data is stored in the order contents are listed within the TMD.
+	// While Nintendo's files index contents from 0 in order, external tools
+	// and DLC titles may not: indexes may be out of order, or sparse.
+	// As such, WAD.Data is ordered by position and not by index.
+	seen := make(map[uint16]bool)
+
+	offsets := make([]int64, len(w.TMD.Contents))
+	var offset int64
+	for idx, content := range w.TMD.Contents {
+		section := fmt.Sprintf("content %d", content.Index)
+
+		if seen[content.Index] {
+			return nil, newParseError(section, offset, ErrDuplicateContentIndex)
+		}
+		seen[content.Index] = true
+
+		// The size of our content must be able to fit within the data section.
+		if content.Size > uint64(size) {
+			return nil, newParseError(section, offset, ErrSectionOverflow)
+		}
+
+		paddedSize := getEncryptedSize(content)
+		if offset+int64(paddedSize) > size {
+			return nil, newParseError(section, offset, ErrSectionOverflow)
+		}
+
+		offsets[idx] = offset
+
+		// Each content within the data section is aligned to a 0x40/64-byte boundary.
+		offset += int64(paddedSize) + int64(getPadding(paddedSize))
+	}
+
+	return offsets, nil
+}
+
 // GetDataSection returns data as specified within the TMD.
-func (w *WAD) GetDataSection() []byte {
+func (w *WAD) GetDataSection() ([]byte, error) {
 	var data []byte
 	for _, content := range w.Data {
+		rawData, err := content.GetRawData()
+		if err != nil {
+			return nil, err
+		}
+
 		// Data internally is aligned by 64 bytes.
-		data = append(data, pad(content.RawData)...)
+		data = append(data, pad(rawData)...)
+	}
+
+	return data, nil
+}
+
+// getContentIV returns the IV used to encrypt and decrypt the content with the given index.
+func getContentIV(index uint16) []byte {
+	// The IV we'll use will be the two bytes sourced from the content's index,
+	// padded with 14 null bytes.
+	iv := make([]byte, 16)
+	binary.BigEndian.PutUint16(iv, index)
+	return iv
+}
+
+// GetRawData returns the encrypted data for this content.
+// If this content is read lazily, its data is read from its source on every call.
+// Similarly, if this content is encrypted on the fly, its data is encrypted on every call.
+func (d *WADFile) GetRawData() ([]byte, error) {
+	if d.plaintext != nil {
+		var tmp bytes.Buffer
+		_, err := d.writeRawData(&tmp)
+		if err != nil {
+			return nil, err
+		}
+
+		return tmp.Bytes(), nil
+	}
+
+	if d.source == nil {
+		return d.RawData, nil
+	}
+
+	rawData := make([]byte, d.source.Size())
+	err := readFullAt(d.source, rawData, 0)
+	if err != nil {
+		return nil, err
 	}
 
-	return data
+	return rawData, nil
 }
 
 // DecryptData returns the decrypted contents of this WADFile with the given title key.
@@ -77,29 +182,47 @@ func (d *WADFile) DecryptData(titleKey [16]byte) ([]byte, error) {
 		panic(err)
 	}
 
-	// The IV we'll use will be the two bytes sourced from the content's index,
-	// padded with 14 null bytes.
-	var indexBytes [2]byte
-	binary.BigEndian.PutUint16(indexBytes[:], d.Record.Index)
+	iv := getContentIV(d.Record.Index)
+	blockMode := cipher.NewCBCDecrypter(block, iv)
 
-	iv := make([]byte, 16)
-	iv[0] = indexBytes[0]
-	iv[1] = indexBytes[1]
+	// Data to be encrypted on the fly is already decrypted.
+	if d.plaintext != nil {
+		decryptedData := make([]byte, d.plaintext.Size())
+		err = readFullAt(d.plaintext, decryptedData, 0)
+		if err != nil {
+			return nil, err
+		}
 
-	blockMode := cipher.NewCBCDecrypter(block, iv)
+		return decryptedData, nil
+	}
+
+	rawData, err := d.GetRawData()
+	if err != nil {
+		return nil, err
+	}
+
+	// Encrypted data must be aligned to the AES block size, and cover the size of our content.
+	if len(rawData)%aes.BlockSize != 0 || uint64(len(rawData)) < d.Record.Size {
+		return nil, &ParseError{
+			Section: fmt.Sprintf("content %d", d.Record.Index),
+			Err:     ErrTruncated,
+		}
+	}
 
 	// The resulting decrypted contents is the same size as the input, including padding.
-	decryptedData := make([]byte, len(d.RawData))
+	decryptedData := make([]byte, len(rawData))
 
 	// ...and we're off!
-	blockMode.CryptBlocks(decryptedData, d.RawData)
+	blockMode.CryptBlocks(decryptedData, rawData)
 
 	// Trim off the excess padding once decrypted.
 	decryptedData = decryptedData[:d.Record.Size]
 
-	// Ensure that the decrypted data matches the SHA-1 hash given in the contents list.
-	sha := sha1.Sum(decryptedData)
-	if bytes.Compare(sha[:], d.Record.Hash[:]) != 0 {
+	// Ensure that the decrypted data matches the hash given in the contents list.
+	// This is SHA-1, or SHA-256 for version 1 TMDs.
+	hash, expected := d.Record.newHash()
+	hash.Write(decryptedData)
+	if bytes.Compare(hash.Sum(nil), expected) != 0 {
 		return nil, errors.New(fmt.Sprintf("content %08x did not match the noted hash when decrypted", d.Record.ID))
 	}
 
@@ -116,19 +239,13 @@ func (d *WADFile) UpdateData(contents []byte, titleKey [16]byte) {
 		panic(err)
 	}
 
-	// The IV we'll use will be the two bytes sourced from the content's index,
-	// padded with 14 null bytes.
-	var indexBytes [2]byte
-	binary.BigEndian.PutUint16(indexBytes[:], d.Record.Index)
-
-	iv := make([]byte, 16)
-	iv[0] = indexBytes[0]
-	iv[1] = indexBytes[1]
-
+	iv := getContentIV(d.Record.Index)
 	blockMode := cipher.NewCBCEncrypter(block, iv)
 
 	// Update the content record to reflect the hash and size of our new content.
+	// Both hashes are updated, as the TMD may be either version.
 	d.Record.Hash = sha1.Sum(contents)
+	d.Record.SHA256Hash = sha256.Sum256(contents)
 	d.Record.Size = uint64(len(contents))
 
 	// One must pad encrypted content to 16 bytes.
@@ -148,4 +265,100 @@ func (d *WADFile) UpdateData(contents []byte, titleKey [16]byte) {
 	// ...and we're off!
 	blockMode.CryptBlocks(encryptedData, decryptedData)
 	d.RawData = encryptedData
+
+	// This content is no longer read from its original source.
+	d.source = nil
+	d.plaintext = nil
+}
+
+// UpdateDataFromReader updates the contents of this WADFile with data from the given reader,
+// of the given size. The data is hashed immediately, but it is only encrypted with the given
+// title key as it is written. As such, the reader must remain available until then.
+func (d *WADFile) UpdateDataFromReader(source io.ReaderAt, size int64, titleKey [16]byte) error {
+	plaintext := io.NewSectionReader(source, 0, size)
+
+	// Update the content record to reflect the hash and size of our new content.
+	// Both hashes are updated, as the TMD may be either version.
+	sha1Hash := sha1.New()
+	sha256Hash := sha256.New()
+	_, err := io.Copy(io.MultiWriter(sha1Hash, sha256Hash), plaintext)
+	if err != nil {
+		return err
+	}
+
+	copy(d.Record.Hash[:], sha1Hash.Sum(nil))
+	copy(d.Record.SHA256Hash[:], sha256Hash.Sum(nil))
+	d.Record.Size = uint64(size)
+
+	d.RawData = nil
+	d.source = nil
+	d.plaintext = plaintext
+	d.titleKey = titleKey
+	return nil
+}
+
+// getRawDataSize returns the size of the encrypted data for this content.
+func (d *WADFile) getRawDataSize() uint32 {
+	switch {
+	case d.plaintext != nil:
+		return getEncryptedSize(*d.Record)
+	case d.source != nil:
+		return uint32(d.source.Size())
+	default:
+		return uint32(len(d.RawData))
+	}
+}
+
+// writeRawData writes the encrypted data for this content to the given writer,
+// reading from its source or encrypting its data on the fly where necessary.
+func (d *WADFile) writeRawData(out io.Writer) (int64, error) {
+	if d.source != nil {
+		return io.Copy(out, io.NewSectionReader(d.source, 0, d.source.Size()))
+	}
+
+	if d.plaintext == nil {
+		written, err := out.Write(d.RawData)
+		return int64(written), err
+	}
+
+	block, err := aes.NewCipher(d.titleKey[:])
+	if err != nil {
+		panic(err)
+	}
+
+	iv := getContentIV(d.Record.Index)
+	blockMode := cipher.NewCBCEncrypter(block, iv)
+
+	// We encrypt in chunks, padding the final chunk with null bytes to 16 bytes.
+	var total int64
+	chunk := make([]byte, encryptionChunkSize)
+	for offset := int64(0); offset < d.plaintext.Size(); offset += encryptionChunkSize {
+		read, err := d.plaintext.ReadAt(chunk, offset)
+		if err != nil && err != io.EOF {
+			return total, err
+		}
+
+		// Only the final chunk may be shorter.
+		if read < encryptionChunkSize && offset+int64(read) < d.plaintext.Size() {
+			return total, io.ErrUnexpectedEOF
+		}
+
+		encryptedSize := read
+		leftover := read % 16
+		if leftover != 0 {
+			encryptedSize += 16 - leftover
+			for i := read; i < encryptedSize; i++ {
+				chunk[i] = 0x00
+			}
+		}
+
+		blockMode.CryptBlocks(chunk[:encryptedSize], chunk[:encryptedSize])
+		written, err := out.Write(chunk[:encryptedSize])
+		total += int64(written)
+		if err != nil {
+			return total, err
+		}
+	}
+
+	return total, nil
 }
@@ -24,7 +24,7 @@ func (w *WAD) LoadHeader(source []byte) error {
 	loadingBuf := bytes.NewBuffer(source)
 	err := binary.Read(loadingBuf, binary.BigEndian, &header)
 	if err != nil {
-		return err
+		return newParseError("header", 0, err)
 	}
 	w.Header = header
 	return nil
@@ -0,0 +1,321 @@
+// Package imet reads and writes the IMET banner header held at the beginning of a channel's
+// first content, alongside the IMD5-wrapped icon, banner and sound files following it.
+package imet
+
+import (
+	"bytes"
+	"crypto/md5"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"unicode/utf16"
+
+	"github.com/wii-tools/wadlib/u8"
+)
+
+var (
+	ErrInvalidMagic = errors.New("data does not begin with an IMET header")
+	ErrHashMismatch = errors.New("banner data does not match its MD5 hash")
+	ErrNameTooLong  = errors.New("channel name exceeds 42 characters")
+	ErrInvalidIMD5  = errors.New("data is not a valid IMD5 file")
+)
+
+var imetMagic = [4]byte{'I', 'M', 'E', 'T'}
+var imd5Magic = [4]byte{'I', 'M', 'D', '5'}
+
+const (
+	// HeaderSize is the size of the IMET header, including the padding preceding it.
+	// The U8 archive holding the icon, banner and sound immediately follows.
+	HeaderSize = 0x600
+	// imd5HeaderSize is the size of the header preceding an IMD5 file's data.
+	imd5HeaderSize = 0x20
+	// nameLength is the maximum length of a channel name in UTF-16 characters.
+	nameLength = 42
+)
+
+// The paths of the icon, banner and sound within the U8 archive.
+const (
+	IconPath   = "meta/icon.bin"
+	BannerPath = "meta/banner.bin"
+	SoundPath  = "meta/sound.bin"
+)
+
+// Language describes the language of a channel name, by its position within the header.
+type Language int
+
+const (
+	LanguageJapanese Language = iota
+	LanguageEnglish
+	LanguageGerman
+	LanguageFrench
+	LanguageSpanish
+	LanguageItalian
+	LanguageDutch
+	LanguageSimplifiedChinese
+	LanguageTraditionalChinese
+	LanguageKorean
+	// LanguageCount is the amount of languages within the header, rather than a language itself.
+	LanguageCount
+)
+
+func (l Language) String() string {
+	switch l {
+	case LanguageJapanese:
+		return "Japanese"
+	case LanguageEnglish:
+		return "English"
+	case LanguageGerman:
+		return "German"
+	case LanguageFrench:
+		return "French"
+	case LanguageSpanish:
+		return "Spanish"
+	case LanguageItalian:
+		return "Italian"
+	case LanguageDutch:
+		return "Dutch"
+	case LanguageSimplifiedChinese:
+		return "Simplified Chinese"
+	case LanguageTraditionalChinese:
+		return "Traditional Chinese"
+	case LanguageKorean:
+		return "Korean"
+	default:
+		return fmt.Sprintf("Language(%d)", int(l))
+	}
+}
+
+// binaryHeader describes the byte-level format of the IMET header.
+type binaryHeader struct {
+	// Padding precedes the header, and is typically null.
+	Padding [0x40]byte
+	Magic   [4]byte
+	// HashSize is the amount of data covered by Hash, from the start of the padding.
+	HashSize uint32
+	Unknown  uint32
+	// IconSize, BannerSize and SoundSize are the sizes of their IMD5 files within the archive.
+	IconSize   uint32
+	BannerSize uint32
+	SoundSize  uint32
+	Flags      uint32
+	// Names holds the channel's name in every language as UTF-16.
+	Names    [LanguageCount][nameLength]uint16
+	Padding2 [0x24c]byte
+	// Hash is the MD5 hash of the header, calculated with this field zeroed.
+	Hash [16]byte
+}
+
+// Banner describes the IMET header and the archive following it.
+type Banner struct {
+	// Names holds the channel's name in every language, indexed by Language.
+	Names [LanguageCount]string
+	Flags uint32
+	// Archive holds the IMD5-wrapped icon, banner and sound.
+	// Consider using GetFile to obtain their contents.
+	Archive *u8.Archive
+}
+
+// Load parses the IMET header and archive held within the given data, such as a channel's first content.
+func Load(data []byte) (*Banner, error) {
+	var header binaryHeader
+	err := binary.Read(bytes.NewReader(data), binary.BigEndian, &header)
+	if err != nil || header.Magic != imetMagic {
+		return nil, ErrInvalidMagic
+	}
+
+	// The hash is calculated with itself zeroed.
+	expected := header.Hash
+	if header.HashSize != HeaderSize || headerHash(header) != expected {
+		return nil, ErrHashMismatch
+	}
+
+	archive, err := u8.Load(data[HeaderSize:])
+	if err != nil {
+		return nil, err
+	}
+
+	banner := Banner{
+		Flags:   header.Flags,
+		Archive: archive,
+	}
+	for language, name := range header.Names {
+		banner.Names[language] = decodeName(name)
+	}
+
+	return &banner, nil
+}
+
+// headerHash returns the MD5 hash of the given header with its hash zeroed.
+func headerHash(header binaryHeader) [16]byte {
+	header.Hash = [16]byte{}
+
+	var tmp bytes.Buffer
+	err := binary.Write(&tmp, binary.BigEndian, header)
+	if err != nil {
+		// It should not be possible to fail writing to memory.
+		panic(err)
+	}
+
+	return md5.Sum(tmp.Bytes())
+}
+
+// decodeName decodes the given null-terminated UTF-16 name.
+func decodeName(name [nameLength]uint16) string {
+	length := 0
+	for length < nameLength && name[length] != 0 {
+		length++
+	}
+
+	return string(utf16.Decode(name[:length]))
+}
+
+// encodeName encodes the given name as null-terminated UTF-16.
+func encodeName(name string) ([nameLength]uint16, error) {
+	var encoded [nameLength]uint16
+	characters := utf16.Encode([]rune(name))
+	if len(characters) > nameLength {
+		return encoded, ErrNameTooLong
+	}
+
+	copy(encoded[:], characters)
+	return encoded, nil
+}
+
+// GetName returns the channel's name in the given language.
+func (b *Banner) GetName(language Language) string {
+	if language < 0 || language >= LanguageCount {
+		return ""
+	}
+
+	return b.Names[language]
+}
+
+// GetFile returns the contents of the IMD5 file with the given path, such as IconPath.
+// These contents are typically LZ77 compressed.
+func (b *Banner) GetFile(path string) ([]byte, error) {
+	contents, err := b.Archive.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return UnwrapIMD5(contents)
+}
+
+// Bytes rebuilds the IMET header and archive with this banner's names and files,
+// recalculating all hashes and sizes. This is useful after altering names.
+// To replace the icon, banner or sound, use Build with the contents of the others via GetFile.
+func (b *Banner) Bytes() ([]byte, error) {
+	icon, err := b.GetFile(IconPath)
+	if err != nil {
+		return nil, err
+	}
+
+	banner, err := b.GetFile(BannerPath)
+	if err != nil {
+		return nil, err
+	}
+
+	sound, err := b.GetFile(SoundPath)
+	if err != nil {
+		return nil, err
+	}
+
+	return Build(b.Names, b.Flags, icon, banner, sound)
+}
+
+// Build creates an IMET header and archive with the given names and files.
+// The icon, banner and sound are wrapped in IMD5, and should typically already be LZ77 compressed.
+func Build(names [LanguageCount]string, flags uint32, icon []byte, banner []byte, sound []byte) ([]byte, error) {
+	files := map[string][]byte{
+		IconPath:   WrapIMD5(icon),
+		BannerPath: WrapIMD5(banner),
+		SoundPath:  WrapIMD5(sound),
+	}
+
+	builder := u8.NewBuilder()
+	for path, contents := range files {
+		err := builder.AddFile(path, contents)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	archive, err := builder.Bytes()
+	if err != nil {
+		return nil, err
+	}
+
+	header := binaryHeader{
+		Magic:      imetMagic,
+		HashSize:   HeaderSize,
+		Unknown:    3,
+		IconSize:   uint32(len(files[IconPath])),
+		BannerSize: uint32(len(files[BannerPath])),
+		SoundSize:  uint32(len(files[SoundPath])),
+		Flags:      flags,
+	}
+	for language, name := range names {
+		header.Names[language], err = encodeName(name)
+		if err != nil {
+			return nil, err
+		}
+	}
+	header.Hash = headerHash(header)
+
+	var tmp bytes.Buffer
+	err = binary.Write(&tmp, binary.BigEndian, header)
+	if err != nil {
+		return nil, err
+	}
+
+	tmp.Write(archive)
+	return tmp.Bytes(), nil
+}
+
+// binaryIMD5Header describes the byte-level format of the header preceding an IMD5 file's data.
+type binaryIMD5Header struct {
+	Magic   [4]byte
+	Size    uint32
+	Padding [8]byte
+	// Hash is the MD5 hash of the data following this header.
+	Hash [16]byte
+}
+
+// UnwrapIMD5 returns the data within the given IMD5 file, ensuring it matches its hash.
+func UnwrapIMD5(contents []byte) ([]byte, error) {
+	var header binaryIMD5Header
+	err := binary.Read(bytes.NewReader(contents), binary.BigEndian, &header)
+	if err != nil || header.Magic != imd5Magic {
+		return nil, ErrInvalidIMD5
+	}
+
+	if uint64(header.Size) > uint64(len(contents)-imd5HeaderSize) {
+		return nil, ErrInvalidIMD5
+	}
+
+	data := contents[imd5HeaderSize : imd5HeaderSize+header.Size]
+	if md5.Sum(data) != header.Hash {
+		return nil, ErrHashMismatch
+	}
+
+	return data, nil
+}
+
+// WrapIMD5 returns the given data within an IMD5 file.
+func WrapIMD5(data []byte) []byte {
+	header := binaryIMD5Header{
+		Magic: imd5Magic,
+		Size:  uint32(len(data)),
+		Hash:  md5.Sum(data),
+	}
+
+	var tmp bytes.Buffer
+	err := binary.Write(&tmp, binary.BigEndian, header)
+	if err != nil {
+		// It should not be possible to fail writing to memory.
+		panic(err)
+	}
+
+	tmp.Write(data)
+	return tmp.Bytes()
+}
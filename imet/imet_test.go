@@ -0,0 +1,143 @@
+package imet
+
+import (
+	"bytes"
+	"crypto/md5"
+	"encoding/binary"
+	"testing"
+	"unicode/utf16"
+
+	"github.com/wii-tools/wadlib/u8"
+)
+
+var testNames = [LanguageCount]string{
+	LanguageJapanese:           "テストチャンネル",
+	LanguageEnglish:            "Test Channel",
+	LanguageGerman:             "Testkanal",
+	LanguageFrench:             "Chaîne de test",
+	LanguageSpanish:            "Canal de prueba",
+	LanguageItalian:            "Canale di prova",
+	LanguageDutch:              "Testkanaal",
+	LanguageSimplifiedChinese:  "测试频道",
+	LanguageTraditionalChinese: "測試頻道",
+	LanguageKorean:             "테스트 채널",
+}
+
+func TestRoundTrip(t *testing.T) {
+	icon, banner, sound := []byte("icon"), []byte("banner"), []byte("sound")
+	data, err := Build(testNames, 0x1234, icon, banner, sound)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	loaded, err := Load(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for language := Language(0); language < LanguageCount; language++ {
+		if name := loaded.GetName(language); name != testNames[language] {
+			t.Errorf("%s name is %q, expected %q", language, name, testNames[language])
+		}
+	}
+
+	if loaded.Flags != 0x1234 {
+		t.Errorf("flags are %x, expected 1234", loaded.Flags)
+	}
+
+	for path, expected := range map[string][]byte{IconPath: icon, BannerPath: banner, SoundPath: sound} {
+		contents, err := loaded.GetFile(path)
+		if err != nil {
+			t.Errorf("%s: %v", path, err)
+		} else if !bytes.Equal(contents, expected) {
+			t.Errorf("%s: contents differ after round trip", path)
+		}
+	}
+
+	rebuilt, err := loaded.Bytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(rebuilt, data) {
+		t.Error("rebuilt banner differs")
+	}
+}
+
+func TestNameTooLong(t *testing.T) {
+	var names [LanguageCount]string
+	names[LanguageEnglish] = string(bytes.Repeat([]byte("a"), nameLength+1))
+	_, err := Build(names, 0, nil, nil, nil)
+	if err != ErrNameTooLong {
+		t.Errorf("returned %v, expected ErrNameTooLong", err)
+	}
+}
+
+// TestHeaderLayout assembles a header by offset, as found at the start of a channel's first content,
+// ensuring it is parsed and that its MD5 covers all 0x600 bytes including the leading padding.
+func TestHeaderLayout(t *testing.T) {
+	builder := u8.NewBuilder()
+	for _, path := range []string{IconPath, BannerPath, SoundPath} {
+		builder.AddFile(path, WrapIMD5([]byte(path)))
+	}
+	archive, err := builder.Bytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	header := make([]byte, HeaderSize)
+	copy(header[0x40:], "IMET")
+	binary.BigEndian.PutUint32(header[0x44:], HeaderSize)
+	binary.BigEndian.PutUint32(header[0x48:], 3)
+	for index, name := range testNames {
+		for position, character := range utf16.Encode([]rune(name)) {
+			binary.BigEndian.PutUint16(header[0x5c+index*nameLength*2+position*2:], character)
+		}
+	}
+
+	hash := md5.Sum(header)
+	copy(header[0x5f0:], hash[:])
+
+	loaded, err := Load(append(header, archive...))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if loaded.GetName(LanguageKorean) != testNames[LanguageKorean] {
+		t.Errorf("Korean name is %q, expected %q", loaded.GetName(LanguageKorean), testNames[LanguageKorean])
+	}
+
+	// The leading padding is covered by the hash.
+	header[0] = 0xff
+	if _, err = Load(append(header, archive...)); err != ErrHashMismatch {
+		t.Errorf("altered padding returned %v, expected ErrHashMismatch", err)
+	}
+
+	// Banners we build must also hash as such.
+	built, err := Build(testNames, 0, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	zeroed := append([]byte{}, built[:HeaderSize]...)
+	copy(zeroed[0x5f0:], make([]byte, 16))
+	if hash = md5.Sum(zeroed); !bytes.Equal(built[0x5f0:HeaderSize], hash[:]) {
+		t.Error("built header does not hold the MD5 of its first 0x600 bytes")
+	}
+}
+
+func TestIMD5HashMismatch(t *testing.T) {
+	wrapped := WrapIMD5([]byte("banner data"))
+	if data, err := UnwrapIMD5(wrapped); err != nil || string(data) != "banner data" {
+		t.Fatalf("unwrapped %q with error %v", data, err)
+	}
+
+	wrapped[imd5HeaderSize] ^= 0xff
+	if _, err := UnwrapIMD5(wrapped); err != ErrHashMismatch {
+		t.Errorf("returned %v, expected ErrHashMismatch", err)
+	}
+
+	if _, err := UnwrapIMD5(wrapped[:imd5HeaderSize-1]); err != ErrInvalidIMD5 {
+		t.Errorf("truncated header returned %v, expected ErrInvalidIMD5", err)
+	}
+}
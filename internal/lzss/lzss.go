@@ -0,0 +1,146 @@
+// Package lzss holds the sliding window and match finding shared by the LZ77 and Yaz0 codecs.
+package lzss
+
+import (
+	"errors"
+)
+
+// WindowSize is the furthest distance a back-reference may reach within all supported formats.
+const WindowSize = 0x1000
+
+// MinLength is the shortest back-reference worth encoding within all supported formats.
+const MinLength = 3
+
+var ErrInvalidDistance = errors.New("compressed data references data preceding its start")
+
+// Window holds recently decompressed data for back-references, alongside data not yet read.
+type Window struct {
+	history [WindowSize]byte
+	written int64
+	// pending holds decompressed data, of which the first read bytes have been read.
+	pending []byte
+	read    int
+}
+
+// Written returns the amount of data decompressed thus far.
+func (w *Window) Written() int64 {
+	return w.written
+}
+
+// Literal appends the given byte.
+func (w *Window) Literal(b byte) {
+	w.history[w.written%WindowSize] = b
+	w.written++
+	w.pending = append(w.pending, b)
+}
+
+// Copy appends the given amount of data from the given distance behind.
+// The source and destination may overlap, repeating data.
+func (w *Window) Copy(distance int, length int) error {
+	if distance < 1 || distance > WindowSize || int64(distance) > w.written {
+		return ErrInvalidDistance
+	}
+
+	for i := 0; i < length; i++ {
+		w.Literal(w.history[(w.written-int64(distance))%WindowSize])
+	}
+	return nil
+}
+
+// Pending returns whether data is available to be read.
+func (w *Window) Pending() bool {
+	return w.read < len(w.pending)
+}
+
+// Read copies data not yet read into the given buffer.
+func (w *Window) Read(p []byte) int {
+	read := copy(p, w.pending[w.read:])
+	w.read += read
+
+	// Reuse our buffer once drained.
+	if w.read == len(w.pending) {
+		w.pending = w.pending[:0]
+		w.read = 0
+	}
+	return read
+}
+
+const (
+	// hashSize is the amount of hash chains, being 14 bits.
+	hashSize = 1 << 14
+	// maxChain is the amount of candidates considered per match.
+	maxChain = 256
+)
+
+// Matcher finds the longest previous occurrence of data within a window.
+// Positions must be inserted in order for them to be found.
+type Matcher struct {
+	data      []byte
+	maxLength int
+	// head holds the last position inserted for every hash, plus one.
+	head []int
+	// prev holds the previous position with the same hash for every position, plus one.
+	prev []int
+}
+
+// NewMatcher returns a Matcher for the given data, finding matches of up to the given length.
+func NewMatcher(data []byte, maxLength int) *Matcher {
+	return &Matcher{
+		data:      data,
+		maxLength: maxLength,
+		head:      make([]int, hashSize),
+		prev:      make([]int, len(data)),
+	}
+}
+
+func (m *Matcher) hash(position int) int {
+	value := uint32(m.data[position])<<16 | uint32(m.data[position+1])<<8 | uint32(m.data[position+2])
+	return int((value * 2654435761) >> (32 - 14))
+}
+
+// Insert makes the given position available for future matches.
+func (m *Matcher) Insert(position int) {
+	if position+MinLength > len(m.data) {
+		return
+	}
+
+	hash := m.hash(position)
+	m.prev[position] = m.head[hash]
+	m.head[hash] = position + 1
+}
+
+// Find returns the length and distance of the longest match for data at the given position.
+// A length of 0 is returned if no match of at least MinLength exists.
+func (m *Matcher) Find(position int) (int, int) {
+	if position+MinLength > len(m.data) {
+		return 0, 0
+	}
+
+	limit := len(m.data) - position
+	if limit > m.maxLength {
+		limit = m.maxLength
+	}
+
+	bestLength, bestDistance := 0, 0
+	candidate := m.head[m.hash(position)] - 1
+	for chain := 0; candidate >= 0 && position-candidate <= WindowSize && chain < maxChain; chain++ {
+		length := 0
+		for length < limit && m.data[candidate+length] == m.data[position+length] {
+			length++
+		}
+
+		if length > bestLength {
+			bestLength, bestDistance = length, position-candidate
+			if length == limit {
+				break
+			}
+		}
+
+		candidate = m.prev[candidate] - 1
+	}
+
+	if bestLength < MinLength {
+		return 0, 0
+	}
+	return bestLength, bestDistance
+}
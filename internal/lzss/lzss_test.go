@@ -0,0 +1,62 @@
+package lzss
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestWindowCopy(t *testing.T) {
+	var w Window
+	w.Literal('a')
+	w.Literal('b')
+
+	// Overlapping copies repeat data.
+	err := w.Copy(2, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	out := make([]byte, 16)
+	read := w.Read(out)
+	if !bytes.Equal(out[:read], []byte("abababa")) {
+		t.Errorf("read %q, expected %q", out[:read], "abababa")
+	}
+
+	if w.Pending() || w.Written() != 7 {
+		t.Errorf("window has pending data or has written %d bytes", w.Written())
+	}
+}
+
+func TestWindowInvalidDistance(t *testing.T) {
+	var w Window
+	w.Literal('a')
+
+	for _, distance := range []int{0, 2, WindowSize + 1} {
+		if err := w.Copy(distance, 3); err != ErrInvalidDistance {
+			t.Errorf("distance %d returned %v, expected ErrInvalidDistance", distance, err)
+		}
+	}
+}
+
+func TestMatcher(t *testing.T) {
+	data := []byte("abcdefabcdefabcxyz")
+	m := NewMatcher(data, 0x12)
+	for position := 0; position < 6; position++ {
+		if length, _ := m.Find(position); length != 0 {
+			t.Errorf("found a match of %d bytes at %d before any repetition", length, position)
+		}
+		m.Insert(position)
+	}
+
+	length, distance := m.Find(6)
+	if length != 9 || distance != 6 {
+		t.Errorf("found a match of %d bytes at a distance of %d, expected 9 at 6", length, distance)
+	}
+
+	// Matches must not exceed the maximum length.
+	limited := NewMatcher(bytes.Repeat([]byte("a"), 0x40), 0x12)
+	limited.Insert(0)
+	if length, _ := limited.Find(1); length != 0x12 {
+		t.Errorf("found a match of %d bytes, expected 0x12", length)
+	}
+}
@@ -0,0 +1,158 @@
+package wadlib
+
+import (
+	"encoding/binary"
+	"errors"
+	"io/ioutil"
+)
+
+var (
+	ErrUnknownKeyType = errors.New("no common key is available for the ticket's key type")
+	ErrInvalidKeyFile = errors.New("key file is not a common key, OTP dump or keys.bin")
+)
+
+// KeyProvider provides the common keys used to encrypt title keys within tickets.
+// A ticket's KeyType determines which common key is requested.
+type KeyProvider interface {
+	// GetCommonKey returns the common key for the given key type,
+	// or ErrUnknownKeyType if it is not available.
+	GetCommonKey(keyType KeyType) ([16]byte, error)
+}
+
+// DefaultKeyProvider is used by WADs and tickets without a key provider of their own.
+// By default, it provides CommonKey, KoreanKey and WiiUvWiiKey.
+var DefaultKeyProvider KeyProvider = builtinKeyProvider{}
+
+// builtinKeyProvider provides the keys held within this package.
+// They are read on every call, so that changes to them are respected.
+type builtinKeyProvider struct{}
+
+func (builtinKeyProvider) GetCommonKey(keyType KeyType) ([16]byte, error) {
+	switch keyType {
+	case KeyTypeCommon:
+		return CommonKey, nil
+	case KeyTypeKorean:
+		return KoreanKey, nil
+	case KeyTypevWii:
+		return WiiUvWiiKey, nil
+	default:
+		return [16]byte{}, ErrUnknownKeyType
+	}
+}
+
+// StaticKeyProvider provides the given common keys by their key type.
+// For example, development titles use a separate common key under KeyTypeCommon.
+type StaticKeyProvider map[KeyType][16]byte
+
+func (s StaticKeyProvider) GetCommonKey(keyType KeyType) ([16]byte, error) {
+	key, ok := s[keyType]
+	if !ok {
+		return [16]byte{}, ErrUnknownKeyType
+	}
+
+	return key, nil
+}
+
+const (
+	// otpSize is the size of a console's OTP, as dumped.
+	otpSize = 0x80
+	// keysBinSize is the size of keys.bin as dumped by BootMii.
+	// Following a 0x100-byte human-readable header, it contains the console's OTP and SEEPROM.
+	keysBinSize   = 0x400
+	keysBinOTPOff = 0x100
+)
+
+// OTPKeys holds the keys within a console's OTP, or within keys.bin as dumped by BootMii.
+// It provides the common key to tickets using KeyTypeCommon.
+type OTPKeys struct {
+	Boot1Hash [20]byte
+	CommonKey [16]byte
+	// ConsoleID is referred to as the NG ID elsewhere.
+	ConsoleID uint32
+	// ConsolePrivateKey is the console's ECC private key,
+	// as used by Ticket.GetPersonalizedTitleKey and Ticket.Depersonalize.
+	ConsolePrivateKey [30]byte
+	// NANDHMACKey begins within the final 2 bytes of ConsolePrivateKey, as they overlap within the OTP.
+	NANDHMACKey [20]byte
+	NANDKey     [16]byte
+	RNGKey      [16]byte
+	Unknown     [8]byte
+}
+
+// LoadOTP parses the given OTP dump.
+func LoadOTP(source []byte) (*OTPKeys, error) {
+	if len(source) != otpSize {
+		return nil, ErrInvalidKeyFile
+	}
+
+	// As the NAND HMAC key overlaps the console's private key,
+	// each field is read from its own offset rather than in sequence.
+	var keys OTPKeys
+	copy(keys.Boot1Hash[:], source[0x00:0x14])
+	copy(keys.CommonKey[:], source[0x14:0x24])
+	keys.ConsoleID = binary.BigEndian.Uint32(source[0x24:0x28])
+	copy(keys.ConsolePrivateKey[:], source[0x28:0x46])
+	copy(keys.NANDHMACKey[:], source[0x44:0x58])
+	copy(keys.NANDKey[:], source[0x58:0x68])
+	copy(keys.RNGKey[:], source[0x68:0x78])
+	copy(keys.Unknown[:], source[0x78:0x80])
+
+	return &keys, nil
+}
+
+// LoadKeysBin parses the OTP within the given keys.bin, as dumped by BootMii.
+func LoadKeysBin(source []byte) (*OTPKeys, error) {
+	if len(source) != keysBinSize {
+		return nil, ErrInvalidKeyFile
+	}
+
+	return LoadOTP(source[keysBinOTPOff : keysBinOTPOff+otpSize])
+}
+
+func (k *OTPKeys) GetCommonKey(keyType KeyType) ([16]byte, error) {
+	if keyType != KeyTypeCommon {
+		return [16]byte{}, ErrUnknownKeyType
+	}
+
+	return k.CommonKey, nil
+}
+
+// LoadKeyProviderFromFile loads a key provider from the file at the given path.
+// This may be a 16-byte common key such as common-key.bin, an OTP dump, or keys.bin.
+func LoadKeyProviderFromFile(path string) (KeyProvider, error) {
+	contents, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var keys *OTPKeys
+	switch len(contents) {
+	case 16:
+		var key [16]byte
+		copy(key[:], contents)
+		return StaticKeyProvider{KeyTypeCommon: key}, nil
+	case otpSize:
+		keys, err = LoadOTP(contents)
+	case keysBinSize:
+		keys, err = LoadKeysBin(contents)
+	default:
+		return nil, ErrInvalidKeyFile
+	}
+
+	if err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
+
+// SetKeyProvider sets the key provider used for this WAD's ticket.
+// If nil, DefaultKeyProvider is used.
+func (w *WAD) SetKeyProvider(keys KeyProvider) {
+	w.Ticket.SetKeyProvider(keys)
+}
+
+// SetKeyProvider sets the key provider used for this ticket.
+// If nil, DefaultKeyProvider is used.
+func (t *Ticket) SetKeyProvider(keys KeyProvider) {
+	t.keys = keys
+}
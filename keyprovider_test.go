@@ -0,0 +1,159 @@
+package wadlib
+
+import (
+	"bytes"
+	"encoding/hex"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+)
+
+// testOTP returns an OTP dump with each byte holding its offset.
+func testOTP() []byte {
+	otp := make([]byte, otpSize)
+	for offset := range otp {
+		otp[offset] = byte(offset)
+	}
+	return otp
+}
+
+// testKeysBin returns a keys.bin holding testOTP, surrounded by unrelated data.
+func testKeysBin() []byte {
+	keysBin := bytes.Repeat([]byte{0xee}, keysBinSize)
+	copy(keysBin, "BackupMii v1, ConsoleID: 0badcafe\n")
+	copy(keysBin[0x100:], testOTP())
+	return keysBin
+}
+
+// testOTPKeys ensures the given keys were read from testOTP, per the OTP's layout.
+func testOTPKeys(t *testing.T, keys *OTPKeys) {
+	t.Helper()
+
+	for _, test := range []struct {
+		name     string
+		field    []byte
+		expected string
+	}{
+		{"boot1 hash", keys.Boot1Hash[:], "000102030405060708090a0b0c0d0e0f10111213"},
+		{"common key", keys.CommonKey[:], "1415161718191a1b1c1d1e1f20212223"},
+		{"console private key", keys.ConsolePrivateKey[:], "28292a2b2c2d2e2f303132333435363738393a3b3c3d3e3f404142434445"},
+		// The NAND HMAC key begins within the final 2 bytes of the console's private key.
+		{"NAND HMAC key", keys.NANDHMACKey[:], "4445464748494a4b4c4d4e4f5051525354555657"},
+		{"NAND key", keys.NANDKey[:], "58595a5b5c5d5e5f6061626364656667"},
+		{"RNG key", keys.RNGKey[:], "68696a6b6c6d6e6f7071727374757677"},
+		{"unknown", keys.Unknown[:], "78797a7b7c7d7e7f"},
+	} {
+		if hex.EncodeToString(test.field) != test.expected {
+			t.Errorf("%s is %x, expected %s", test.name, test.field, test.expected)
+		}
+	}
+
+	if keys.ConsoleID != 0x24252627 {
+		t.Errorf("console ID is %08x, expected 24252627", keys.ConsoleID)
+	}
+}
+
+func TestLoadOTP(t *testing.T) {
+	keys, err := LoadOTP(testOTP())
+	if err != nil {
+		t.Fatal(err)
+	}
+	testOTPKeys(t, keys)
+
+	keys, err = LoadKeysBin(testKeysBin())
+	if err != nil {
+		t.Fatal(err)
+	}
+	testOTPKeys(t, keys)
+
+	if _, err = LoadOTP(testOTP()[:otpSize-1]); err != ErrInvalidKeyFile {
+		t.Errorf("short OTP returned %v, expected ErrInvalidKeyFile", err)
+	}
+
+	if _, err = LoadKeysBin(testOTP()); err != ErrInvalidKeyFile {
+		t.Errorf("OTP as keys.bin returned %v, expected ErrInvalidKeyFile", err)
+	}
+}
+
+func TestLoadKeyProviderFromFile(t *testing.T) {
+	dir := t.TempDir()
+	commonKey := bytes.Repeat([]byte{0x42}, 16)
+
+	for _, test := range []struct {
+		name     string
+		contents []byte
+		expected []byte
+	}{
+		{"common-key.bin", commonKey, commonKey},
+		{"otp.bin", testOTP(), testOTP()[0x14:0x24]},
+		{"keys.bin", testKeysBin(), testOTP()[0x14:0x24]},
+	} {
+		path := filepath.Join(dir, test.name)
+		if err := ioutil.WriteFile(path, test.contents, 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		keys, err := LoadKeyProviderFromFile(path)
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+
+		key, err := keys.GetCommonKey(KeyTypeCommon)
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+
+		if !bytes.Equal(key[:], test.expected) {
+			t.Errorf("%s: common key is %x, expected %x", test.name, key, test.expected)
+		}
+	}
+
+	path := filepath.Join(dir, "invalid.bin")
+	if err := ioutil.WriteFile(path, make([]byte, 17), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := LoadKeyProviderFromFile(path); err != ErrInvalidKeyFile {
+		t.Errorf("17-byte file returned %v, expected ErrInvalidKeyFile", err)
+	}
+}
+
+func TestUnknownKeyType(t *testing.T) {
+	otpKeys, err := LoadOTP(testOTP())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, test := range []struct {
+		name string
+		keys KeyProvider
+	}{
+		{"default", nil},
+		{"static", StaticKeyProvider{KeyTypeCommon: CommonKey}},
+		{"OTP", otpKeys},
+	} {
+		wad := WAD{}
+		if err = wad.LoadTicket(TicketTemplate); err != nil {
+			t.Fatal(err)
+		}
+
+		ticket := wad.Ticket
+		ticket.SetKeyProvider(test.keys)
+
+		// Some fakesigning utilities use a key type outside of those known,
+		// which must not fall back to the common key.
+		ticket.KeyType = 0x7f
+		if _, err = ticket.GetTitleKey(); err != ErrUnknownKeyType {
+			t.Errorf("%s: returned %v, expected ErrUnknownKeyType", test.name, err)
+		}
+
+		if err = ticket.UpdateTitleKey(testTitleKey); err != ErrUnknownKeyType {
+			t.Errorf("%s: updating returned %v, expected ErrUnknownKeyType", test.name, err)
+		}
+	}
+
+	// Providers without a key for a known type must not fall back either.
+	if _, err = otpKeys.GetCommonKey(KeyTypeKorean); err != ErrUnknownKeyType {
+		t.Errorf("OTP returned %v for the Korean key, expected ErrUnknownKeyType", err)
+	}
+}
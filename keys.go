@@ -1,6 +1,15 @@
 package wadlib
 
+import "crypto/rsa"
+
 var (
+	// RootKey is the public key of the root certificate, used by WAD.VerifySignatures to verify the CA certificate
+	// within a certificate chain. It is not embedded within certificate chains themselves.
+	// This package does not ship the retail root key, so RootKey is nil unless set by the caller.
+	// While nil, certificates issued by the root are reported as unverified, and no chain can be fully validated.
+	// To verify against a given root, use WAD.VerifySignaturesWithRoot rather than altering this.
+	RootKey *rsa.PublicKey
+
 	// CommonKey is the common key for titles in all regions but Korea.
 	// As hex, "ebe42a225e8593e448d9c5457381aaf7"
 	CommonKey = [16]byte{0xeb, 0xe4, 0x2a, 0x22, 0x5e, 0x85, 0x93, 0xe4, 0x48, 0xd9, 0xc5, 0x45, 0x73, 0x81, 0xaa, 0xf7}
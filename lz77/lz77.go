@@ -0,0 +1,366 @@
+// Package lz77 reads and writes data compressed with Nintendo's LZ77 variants, being LZ10 (type 0x10)
+// and LZ11 (type 0x11). Data may optionally be preceded by the "LZ77" magic, as within banners.
+package lz77
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+
+	"github.com/wii-tools/wadlib/internal/lzss"
+)
+
+var (
+	ErrUnsupportedType = errors.New("data is not compressed with a supported LZ77 type")
+	ErrSizeMismatch    = errors.New("decompressed data does not match the size within its header")
+)
+
+// Magic optionally precedes the header of compressed data.
+var Magic = [4]byte{'L', 'Z', '7', '7'}
+
+// Type describes the LZ77 variant used to compress data.
+type Type byte
+
+const (
+	// TypeLZ10 permits back-references of up to 18 bytes.
+	TypeLZ10 Type = 0x10
+	// TypeLZ11 permits back-references of up to 65808 bytes.
+	TypeLZ11 Type = 0x11
+)
+
+// maxLength returns the longest back-reference this type can encode.
+func (t Type) maxLength() int {
+	if t == TypeLZ11 {
+		return 0x10110
+	}
+
+	return 0x12
+}
+
+// maxHeaderSize is the largest size representable within the 24 bits of the header.
+// Larger sizes are stored within a further 32 bits.
+const maxHeaderSize = 0xffffff
+
+// Reader decompresses LZ77 data from an underlying reader as it is read.
+type Reader struct {
+	source         *bufio.Reader
+	compression    Type
+	size           int64
+	window         lzss.Window
+	flags          byte
+	remainingFlags int
+	err            error
+}
+
+// NewReader returns a Reader decompressing from the given reader.
+// Its header, including the optional "LZ77" magic, is read immediately.
+func NewReader(source io.Reader) (*Reader, error) {
+	r := &Reader{
+		source: bufio.NewReader(source),
+	}
+
+	var header [4]byte
+	_, err := io.ReadFull(r.source, header[:])
+	if err != nil {
+		return nil, unexpected(err)
+	}
+
+	if header == Magic {
+		_, err = io.ReadFull(r.source, header[:])
+		if err != nil {
+			return nil, unexpected(err)
+		}
+	}
+
+	r.compression = Type(header[0])
+	if r.compression != TypeLZ10 && r.compression != TypeLZ11 {
+		return nil, ErrUnsupportedType
+	}
+
+	header[0] = 0
+	r.size = int64(binary.LittleEndian.Uint32(header[:]) >> 8)
+	if r.size == 0 {
+		_, err = io.ReadFull(r.source, header[:])
+		if err != nil {
+			return nil, unexpected(err)
+		}
+
+		r.size = int64(binary.LittleEndian.Uint32(header[:]))
+	}
+
+	return r, nil
+}
+
+// unexpected converts EOF to ErrUnexpectedEOF, as compressed data has ended early.
+func unexpected(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+
+	return err
+}
+
+// Type returns the LZ77 variant of this data.
+func (r *Reader) Type() Type {
+	return r.compression
+}
+
+// Size returns the decompressed size of this data.
+func (r *Reader) Size() int64 {
+	return r.size
+}
+
+func (r *Reader) Read(p []byte) (int, error) {
+	for !r.window.Pending() && r.err == nil {
+		if r.window.Written() >= r.size {
+			r.err = io.EOF
+			break
+		}
+
+		r.err = r.next()
+	}
+
+	if r.window.Pending() {
+		return r.window.Read(p), nil
+	}
+	return 0, r.err
+}
+
+// next decompresses the next literal or back-reference.
+func (r *Reader) next() error {
+	// Every 8 literals or back-references are preceded by their flags, from the most significant bit.
+	if r.remainingFlags == 0 {
+		flags, err := r.source.ReadByte()
+		if err != nil {
+			return unexpected(err)
+		}
+
+		r.flags = flags
+		r.remainingFlags = 8
+	}
+
+	compressed := r.flags&0x80 != 0
+	r.flags <<= 1
+	r.remainingFlags--
+
+	if !compressed {
+		literal, err := r.source.ReadByte()
+		if err != nil {
+			return unexpected(err)
+		}
+
+		r.window.Literal(literal)
+		return nil
+	}
+
+	length, distance, err := r.readReference()
+	if err != nil {
+		return err
+	}
+
+	// Back-references must not exceed the size within our header.
+	if remaining := r.size - r.window.Written(); int64(length) > remaining {
+		return ErrSizeMismatch
+	}
+
+	return r.window.Copy(distance, length)
+}
+
+// readReference reads the length and distance of a back-reference.
+func (r *Reader) readReference() (int, int, error) {
+	var reference [4]byte
+	_, err := io.ReadFull(r.source, reference[:2])
+	if err != nil {
+		return 0, 0, unexpected(err)
+	}
+
+	if r.compression == TypeLZ10 {
+		length := int(reference[0]>>4) + 3
+		distance := (int(reference[0]&0xf)<<8 | int(reference[1])) + 1
+		return length, distance, nil
+	}
+
+	// LZ11 uses the upper 4 bits of the first byte to determine the size of the length.
+	switch reference[0] >> 4 {
+	case 0:
+		_, err = io.ReadFull(r.source, reference[2:3])
+		if err != nil {
+			return 0, 0, unexpected(err)
+		}
+
+		length := (int(reference[0]&0xf)<<4 | int(reference[1]>>4)) + 0x11
+		distance := (int(reference[1]&0xf)<<8 | int(reference[2])) + 1
+		return length, distance, nil
+	case 1:
+		_, err = io.ReadFull(r.source, reference[2:4])
+		if err != nil {
+			return 0, 0, unexpected(err)
+		}
+
+		length := (int(reference[0]&0xf)<<12 | int(reference[1])<<4 | int(reference[2]>>4)) + 0x111
+		distance := (int(reference[2]&0xf)<<8 | int(reference[3])) + 1
+		return length, distance, nil
+	default:
+		length := int(reference[0]>>4) + 1
+		distance := (int(reference[0]&0xf)<<8 | int(reference[1])) + 1
+		return length, distance, nil
+	}
+}
+
+// Writer compresses data written to it with LZ77.
+// As the header holds the decompressed size, data is compressed and written once the Writer is closed.
+type Writer struct {
+	destination io.Writer
+	compression Type
+	magic       bool
+	buffer      bytes.Buffer
+	closed      bool
+}
+
+// NewWriter returns a Writer compressing with the given type to the given writer.
+func NewWriter(destination io.Writer, compression Type) *Writer {
+	return &Writer{
+		destination: destination,
+		compression: compression,
+	}
+}
+
+// NewMagicWriter returns a Writer as NewWriter does, preceding the compressed data with the "LZ77" magic.
+func NewMagicWriter(destination io.Writer, compression Type) *Writer {
+	w := NewWriter(destination, compression)
+	w.magic = true
+	return w
+}
+
+func (w *Writer) Write(p []byte) (int, error) {
+	if w.closed {
+		return 0, io.ErrClosedPipe
+	}
+
+	return w.buffer.Write(p)
+}
+
+// Close compresses all data written and writes it to the underlying writer.
+// It does not close the underlying writer.
+func (w *Writer) Close() error {
+	if w.closed {
+		return nil
+	}
+	w.closed = true
+
+	if w.compression != TypeLZ10 && w.compression != TypeLZ11 {
+		return ErrUnsupportedType
+	}
+
+	data := w.buffer.Bytes()
+	out := bufio.NewWriter(w.destination)
+	if w.magic {
+		out.Write(Magic[:])
+	}
+
+	// Sizes exceeding 24 bits are written following the header.
+	var header [8]byte
+	headerSize := 4
+	if len(data) <= maxHeaderSize && len(data) != 0 {
+		binary.LittleEndian.PutUint32(header[:], uint32(len(data))<<8)
+	} else {
+		binary.LittleEndian.PutUint32(header[4:], uint32(len(data)))
+		headerSize = 8
+	}
+	header[0] = byte(w.compression)
+	out.Write(header[:headerSize])
+
+	matcher := lzss.NewMatcher(data, w.compression.maxLength())
+	var block [1 + 8*4]byte
+	for position := 0; position < len(data); {
+		// Every 8 literals or back-references are preceded by their flags.
+		flags := byte(0)
+		blockSize := 1
+		for item := 0; item < 8 && position < len(data); item++ {
+			length, distance := matcher.Find(position)
+			if length == 0 {
+				block[blockSize] = data[position]
+				blockSize++
+				length = 1
+			} else {
+				flags |= 0x80 >> item
+				blockSize += w.encodeReference(block[blockSize:], length, distance)
+			}
+
+			for end := position + length; position < end; position++ {
+				matcher.Insert(position)
+			}
+		}
+
+		block[0] = flags
+		out.Write(block[:blockSize])
+	}
+
+	return out.Flush()
+}
+
+// encodeReference writes the given back-reference, returning its size.
+func (w *Writer) encodeReference(out []byte, length int, distance int) int {
+	distance--
+
+	if w.compression == TypeLZ10 {
+		out[0] = byte(length-3)<<4 | byte(distance>>8)
+		out[1] = byte(distance)
+		return 2
+	}
+
+	switch {
+	case length <= 0x10:
+		out[0] = byte(length-1)<<4 | byte(distance>>8)
+		out[1] = byte(distance)
+		return 2
+	case length <= 0x110:
+		length -= 0x11
+		out[0] = byte(length >> 4)
+		out[1] = byte(length)<<4 | byte(distance>>8)
+		out[2] = byte(distance)
+		return 3
+	default:
+		length -= 0x111
+		out[0] = 0x10 | byte(length>>12)
+		out[1] = byte(length >> 4)
+		out[2] = byte(length)<<4 | byte(distance>>8)
+		out[3] = byte(distance)
+		return 4
+	}
+}
+
+// Decompress returns the decompressed contents of the given LZ77 data.
+func Decompress(data []byte) ([]byte, error) {
+	r, err := NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+
+	var decompressed bytes.Buffer
+	_, err = io.Copy(&decompressed, r)
+	if err != nil {
+		return nil, err
+	}
+
+	return decompressed.Bytes(), nil
+}
+
+// Compress returns the given data compressed with the given type.
+func Compress(data []byte, compression Type) ([]byte, error) {
+	var compressed bytes.Buffer
+	w := NewWriter(&compressed, compression)
+	_, err := w.Write(data)
+	if err != nil {
+		return nil, err
+	}
+
+	err = w.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	return compressed.Bytes(), nil
+}
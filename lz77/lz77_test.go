@@ -0,0 +1,224 @@
+package lz77
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"testing"
+)
+
+// These samples are laid out as Nintendo's encoders emit them, padded to a multiple of 4 bytes.
+var samples = []struct {
+	name       string
+	compressed []byte
+	expected   []byte
+}{
+	{
+		// Literals "abc", followed by a back-reference of 9 bytes at a distance of 3.
+		name:       "LZ10",
+		compressed: []byte{0x10, 0x0c, 0x00, 0x00, 0x10, 'a', 'b', 'c', 0x60, 0x02, 0x00, 0x00},
+		expected:   []byte("abcabcabcabc"),
+	},
+	{
+		// As above, preceded by the "LZ77" magic as within banners.
+		name:       "LZ10 with magic",
+		compressed: []byte{'L', 'Z', '7', '7', 0x10, 0x0c, 0x00, 0x00, 0x10, 'a', 'b', 'c', 0x60, 0x02, 0x00, 0x00},
+		expected:   []byte("abcabcabcabc"),
+	},
+	{
+		// The literal "a", followed by a 3-byte back-reference of 19 bytes at a distance of 1.
+		name:       "LZ11",
+		compressed: []byte{0x11, 0x14, 0x00, 0x00, 0x40, 'a', 0x00, 0x20, 0x00, 0x00, 0x00, 0x00},
+		expected:   bytes.Repeat([]byte("a"), 20),
+	},
+}
+
+func TestDecompressSamples(t *testing.T) {
+	for _, sample := range samples {
+		decompressed, err := Decompress(sample.compressed)
+		if err != nil {
+			t.Errorf("%s: %v", sample.name, err)
+			continue
+		}
+
+		if !bytes.Equal(decompressed, sample.expected) {
+			t.Errorf("%s: decompressed to %q, expected %q", sample.name, decompressed, sample.expected)
+		}
+	}
+}
+
+// testData returns compressible data of the given size.
+func testData(size int) []byte {
+	random := rand.New(rand.NewSource(1))
+	data := make([]byte, size)
+	for i := range data {
+		if random.Intn(4) == 0 {
+			data[i] = byte(random.Intn(256))
+		} else if i >= 8 {
+			data[i] = data[i-1-random.Intn(8)]
+		}
+	}
+	return data
+}
+
+func TestRoundTrip(t *testing.T) {
+	inputs := map[string][]byte{
+		"empty":     {},
+		"single":    {0x42},
+		"text":      []byte("The quick brown fox jumps over the lazy dog. The quick brown fox jumps over the lazy dog."),
+		"random":    testData(0x20000),
+		"long runs": bytes.Repeat([]byte{0x00}, 0x20000),
+	}
+
+	for _, compression := range []Type{TypeLZ10, TypeLZ11} {
+		for name, input := range inputs {
+			compressed, err := Compress(input, compression)
+			if err != nil {
+				t.Fatalf("%x %s: %v", compression, name, err)
+			}
+
+			decompressed, err := Decompress(compressed)
+			if err != nil {
+				t.Fatalf("%x %s: %v", compression, name, err)
+			}
+
+			if !bytes.Equal(decompressed, input) {
+				t.Errorf("%x %s: data differs after round trip", compression, name)
+			}
+		}
+	}
+}
+
+func TestMagicRoundTrip(t *testing.T) {
+	input := testData(0x1000)
+
+	var compressed bytes.Buffer
+	w := NewMagicWriter(&compressed, TypeLZ11)
+	w.Write(input)
+	err := w.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.HasPrefix(compressed.Bytes(), Magic[:]) {
+		t.Fatal("compressed data is not preceded by the magic")
+	}
+
+	r, err := NewReader(&compressed)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if r.Type() != TypeLZ11 || r.Size() != int64(len(input)) {
+		t.Errorf("header describes type %x and size %d", r.Type(), r.Size())
+	}
+
+	decompressed, err := ioutil.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(decompressed, input) {
+		t.Error("data differs after round trip")
+	}
+}
+
+func TestExtendedHeader(t *testing.T) {
+	input := testData(0x1000008)
+	compressed, err := Compress(input, TypeLZ10)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Sizes exceeding 24 bits are held within a further 32 bits.
+	expectedHeader := []byte{0x10, 0x00, 0x00, 0x00, 0x08, 0x00, 0x00, 0x01}
+	if !bytes.Equal(compressed[:8], expectedHeader) {
+		t.Errorf("header is %x, expected %x", compressed[:8], expectedHeader)
+	}
+
+	decompressed, err := Decompress(compressed)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(decompressed, input) {
+		t.Error("data differs after round trip")
+	}
+}
+
+func TestLZ11LongMatch(t *testing.T) {
+	input := append([]byte("prefix"), bytes.Repeat([]byte("x"), 0x800)...)
+	compressed, err := Compress(input, TypeLZ11)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A match longer than 0x110 bytes requires the 4-byte back-reference,
+	// whose first byte has 1 within its upper 4 bits.
+	found := false
+	r := bytes.NewReader(compressed[4:])
+	for !found {
+		flags, err := r.ReadByte()
+		if err != nil {
+			break
+		}
+
+		for bit := 0; bit < 8 && !found; bit++ {
+			first, err := r.ReadByte()
+			if err != nil {
+				break
+			}
+
+			if flags&(0x80>>bit) == 0 {
+				continue
+			}
+
+			switch first >> 4 {
+			case 0:
+				r.Seek(2, io.SeekCurrent)
+			case 1:
+				found = true
+			default:
+				r.Seek(1, io.SeekCurrent)
+			}
+		}
+	}
+
+	if !found {
+		t.Error("no 4-byte back-reference was written")
+	}
+
+	decompressed, err := Decompress(compressed)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(decompressed, input) {
+		t.Error("data differs after round trip")
+	}
+}
+
+func TestTruncated(t *testing.T) {
+	for _, sample := range samples {
+		// Everything but the trailing padding is necessary.
+		for _, length := range []int{2, len(sample.compressed) - 5} {
+			_, err := Decompress(sample.compressed[:length])
+			if err != io.ErrUnexpectedEOF {
+				t.Errorf("%s truncated to %d bytes: returned %v, expected io.ErrUnexpectedEOF", sample.name, length, err)
+			}
+		}
+	}
+}
+
+func TestUnsupportedType(t *testing.T) {
+	_, err := Decompress([]byte{0x30, 0x04, 0x00, 0x00})
+	if err != ErrUnsupportedType {
+		t.Errorf("returned %v, expected ErrUnsupportedType", err)
+	}
+
+	_, err = Compress([]byte("data"), 0x30)
+	if err != ErrUnsupportedType {
+		t.Errorf("returned %v, expected ErrUnsupportedType", err)
+	}
+}
@@ -0,0 +1,296 @@
+package wadlib
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+var ErrNoTMD = errors.New("directory does not contain a TMD")
+
+const (
+	// NUSTMDName is the name of the TMD within a NUS directory.
+	// A specific version may be stored as "tmd.<version>", such as "tmd.513".
+	NUSTMDName = "tmd"
+	// NUSTicketName is the name of the ticket within a NUS directory.
+	NUSTicketName = "cetk"
+)
+
+// NUSContentName returns the name of the file holding the content with the given ID within a NUS directory.
+// This is its ID as 8 lowercase hexadecimal digits, such as "0000000a".
+func NUSContentName(id uint32) string {
+	return fmt.Sprintf("%08x", id)
+}
+
+// ExportNUS writes this WAD to the given directory as laid out by the Nintendo Update Server (NUS).
+// The TMD is written as both "tmd" and "tmd.<version>", and the ticket as "cetk".
+// As served by NUS, the TMD is followed by the certificates of its signer and the CA,
+// and the ticket similarly so. Certificates not present within our chain are omitted.
+// Each content is written encrypted, named per NUSContentName.
+func (w *WAD) ExportNUS(dir string) error {
+	certs, err := w.GetCertificates()
+	if err != nil {
+		return err
+	}
+
+	tmd, err := w.GetTMD()
+	if err != nil {
+		return err
+	}
+
+	ticket, err := w.GetTicket()
+	if err != nil {
+		return err
+	}
+
+	tmd, err = appendIssuerChain(tmd, certs, nullTerminated(w.TMD.Issuer[:]))
+	if err != nil {
+		return err
+	}
+
+	ticket, err = appendIssuerChain(ticket, certs, nullTerminated(w.Ticket.Issuer[:]))
+	if err != nil {
+		return err
+	}
+
+	err = os.MkdirAll(dir, 0755)
+	if err != nil {
+		return err
+	}
+
+	files := map[string][]byte{
+		NUSTMDName: tmd,
+		fmt.Sprintf("%s.%d", NUSTMDName, w.TMD.TitleVersion): tmd,
+		NUSTicketName: ticket,
+	}
+	for name, contents := range files {
+		err = ioutil.WriteFile(filepath.Join(dir, name), contents, 0644)
+		if err != nil {
+			return err
+		}
+	}
+
+	for index := range w.Data {
+		err = exportNUSContent(&w.Data[index], dir)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// exportNUSContent writes the encrypted data of the given content to the given directory.
+func exportNUSContent(content *WADFile, dir string) error {
+	file, err := os.Create(filepath.Join(dir, NUSContentName(content.Record.ID)))
+	if err != nil {
+		return err
+	}
+
+	_, err = content.writeRawData(file)
+	if err != nil {
+		file.Close()
+		return err
+	}
+
+	return file.Close()
+}
+
+// appendIssuerChain appends the certificate with the given issuer to the given contents,
+// followed by the certificate which issued it, until reaching the root.
+func appendIssuerChain(contents []byte, certs []Certificate, issuer string) ([]byte, error) {
+	for issuer != rootIssuer {
+		// We cannot continue past a certificate we do not have.
+		cert, err := FindCertificate(certs, issuer)
+		if err != nil {
+			break
+		}
+
+		certBytes, err := cert.GetBytes()
+		if err != nil {
+			return nil, err
+		}
+
+		contents = append(contents, certBytes...)
+		issuer = cert.GetIssuer()
+	}
+
+	return contents, nil
+}
+
+// LoadFromNUSDirectory loads a WAD from the given directory, as laid out by the Nintendo Update Server (NUS).
+// If present, "tmd" is used as the TMD. Otherwise, the "tmd.<version>" with the highest version is used.
+// The certificates appended to the TMD and "cetk" ticket form the WAD's certificate chain.
+// All contents are read into memory.
+func LoadFromNUSDirectory(dir string) (*WAD, error) {
+	tmdName, err := findNUSTMD(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	tmd, err := ioutil.ReadFile(filepath.Join(dir, tmdName))
+	if err != nil {
+		return nil, err
+	}
+
+	ticket, err := ioutil.ReadFile(filepath.Join(dir, NUSTicketName))
+	if err != nil {
+		return nil, err
+	}
+
+	wad := WAD{}
+	err = wad.LoadNUSMetadata(tmd, ticket)
+	if err != nil {
+		return nil, err
+	}
+
+	// Contents are stored in the order they are listed within the TMD, as with LoadDataSection.
+	seen := make(map[uint16]bool)
+	wad.Data = make([]WADFile, len(wad.TMD.Contents))
+	for idx, content := range wad.TMD.Contents {
+		section := fmt.Sprintf("content %d", content.Index)
+		if seen[content.Index] {
+			return nil, newParseError(section, 0, ErrDuplicateContentIndex)
+		}
+		seen[content.Index] = true
+
+		encryptedData, err := ioutil.ReadFile(filepath.Join(dir, NUSContentName(content.ID)))
+		if err != nil {
+			return nil, err
+		}
+
+		// Some mirrors pad contents further, so we only require enough data to be present.
+		paddedSize := getEncryptedSize(content)
+		if uint64(len(encryptedData)) < uint64(paddedSize) {
+			return nil, newParseError(section, int64(len(encryptedData)), ErrTruncated)
+		}
+
+		wad.Data[idx] = WADFile{
+			Record:  &wad.TMD.Contents[idx],
+			RawData: encryptedData[:paddedSize],
+		}
+	}
+
+	return &wad, nil
+}
+
+// findNUSTMD returns the name of the TMD to use within the given directory.
+func findNUSTMD(dir string) (string, error) {
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return "", err
+	}
+
+	name := ""
+	highest := -1
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+
+		if entry.Name() == NUSTMDName {
+			return NUSTMDName, nil
+		}
+
+		if !strings.HasPrefix(entry.Name(), NUSTMDName+".") {
+			continue
+		}
+
+		version, err := strconv.ParseUint(strings.TrimPrefix(entry.Name(), NUSTMDName+"."), 10, 16)
+		if err == nil && int(version) > highest {
+			name = entry.Name()
+			highest = int(version)
+		}
+	}
+
+	if name == "" {
+		return "", ErrNoTMD
+	}
+
+	return name, nil
+}
+
+// LoadNUSMetadata loads the given TMD and ticket, as served by NUS with certificates appended to each.
+// The certificates from both form our certificate chain, ordered as within a retail WAD.
+// Contents are not loaded, and WAD.Data must be populated separately.
+func (w *WAD) LoadNUSMetadata(tmd []byte, ticket []byte) error {
+	err := w.LoadTMD(tmd)
+	if err != nil {
+		return withOffset("TMD", 0, err)
+	}
+
+	err = w.LoadTicket(ticket)
+	if err != nil {
+		return withOffset("ticket", 0, err)
+	}
+
+	// Our loaded TMD and ticket serialize to the same size as when read,
+	// so any data following them is their certificates.
+	tmdCerts, err := trailingCertificates("TMD", tmd, w.GetTMD)
+	if err != nil {
+		return err
+	}
+
+	ticketCerts, err := trailingCertificates("ticket", ticket, w.GetTicket)
+	if err != nil {
+		return err
+	}
+
+	// Both typically hold the CA certificate, so we must avoid duplicates.
+	var certs []Certificate
+	seen := make(map[string]bool)
+	for _, cert := range append(tmdCerts, ticketCerts...) {
+		if seen[cert.FullName()] {
+			continue
+		}
+
+		seen[cert.FullName()] = true
+		certs = append(certs, cert)
+	}
+
+	// Retail WADs list the CA certificate first, followed by the TMD's signer and then the ticket's signer.
+	tmdIssuer := nullTerminated(w.TMD.Issuer[:])
+	ticketIssuer := nullTerminated(w.Ticket.Issuer[:])
+	rank := func(cert Certificate) int {
+		switch {
+		case cert.GetIssuer() == rootIssuer:
+			return 0
+		case cert.FullName() == tmdIssuer:
+			return 1
+		case cert.FullName() == ticketIssuer:
+			return 2
+		default:
+			return 3
+		}
+	}
+	sort.SliceStable(certs, func(i, j int) bool {
+		return rank(certs[i]) < rank(certs[j])
+	})
+
+	w.CertificateChain, err = BuildCertificateChain(certs)
+	return err
+}
+
+// trailingCertificates parses the certificates following the TMD or ticket within the given contents.
+func trailingCertificates(section string, contents []byte, getBytes func() ([]byte, error)) ([]Certificate, error) {
+	loaded, err := getBytes()
+	if err != nil {
+		return nil, err
+	}
+
+	if len(loaded) > len(contents) {
+		return nil, newParseError(section, 0, ErrTruncated)
+	}
+
+	certs, err := ParseCertificateChain(contents[len(loaded):])
+	if err != nil {
+		return nil, withOffset(section+" certificates", int64(len(loaded)), err)
+	}
+
+	return certs, nil
+}
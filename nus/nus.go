@@ -0,0 +1,368 @@
+// Package nus downloads titles from the Nintendo Update Server (NUS), or any server laid out as such,
+// assembling them into a WAD. Contents are verified against their hashes as they are downloaded.
+package nus
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/wii-tools/wadlib"
+)
+
+var ErrUnexpectedRange = errors.New("server responded with a range other than requested")
+
+// DefaultBaseURL is the location of titles on Nintendo's Update Server.
+const DefaultBaseURL = "http://nus.cdn.shop.wii.com/ccs/download"
+
+// StatusError is returned when the server responds with an unexpected status code,
+// such as 404 for titles or contents that do not exist.
+type StatusError struct {
+	URL        string
+	StatusCode int
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("%s responded with status %d", e.URL, e.StatusCode)
+}
+
+// ContentError is returned when a downloaded content does not match its record within the TMD.
+type ContentError struct {
+	ID     uint32
+	Status wadlib.ContentStatus
+}
+
+func (e *ContentError) Error() string {
+	return fmt.Sprintf("content %08x: %s", e.ID, e.Status)
+}
+
+// Client downloads titles from a server laid out as NUS is.
+// Files are requested as "<base URL>/<title ID>/<name>", with the title ID as 16 hexadecimal digits.
+// As with NUS, names are "tmd", "tmd.<version>", "cetk", or a content ID as 8 hexadecimal digits.
+type Client struct {
+	// BaseURL is the location titles are beneath, such as DefaultBaseURL.
+	BaseURL string
+	// HTTPClient is used for all requests. If nil, http.DefaultClient is used.
+	HTTPClient *http.Client
+	// KeyProvider decrypts the title key needed to verify contents.
+	// If nil, wadlib.DefaultKeyProvider is used.
+	KeyProvider wadlib.KeyProvider
+	// Directory, if set, holds downloaded files within a directory per title ID, laid out as NUS is.
+	// Contents already present are not downloaded again, and partially downloaded contents are resumed.
+	// Otherwise, all files are held only in memory.
+	Directory string
+	// Retries is the amount of times an interrupted download is resumed before failing.
+	Retries int
+}
+
+// NewClient returns a Client downloading from the given base URL, such as DefaultBaseURL.
+func NewClient(baseURL string) *Client {
+	return &Client{
+		BaseURL: strings.TrimSuffix(baseURL, "/"),
+		Retries: 3,
+	}
+}
+
+func (c *Client) httpClient() *http.Client {
+	if c.HTTPClient == nil {
+		return http.DefaultClient
+	}
+
+	return c.HTTPClient
+}
+
+// Download downloads the latest version of the given title.
+func (c *Client) Download(ctx context.Context, titleID wadlib.TitleID) (*wadlib.WAD, error) {
+	return c.download(ctx, titleID, wadlib.NUSTMDName)
+}
+
+// DownloadVersion downloads the given version of the given title.
+func (c *Client) DownloadVersion(ctx context.Context, titleID wadlib.TitleID, version uint16) (*wadlib.WAD, error) {
+	return c.download(ctx, titleID, fmt.Sprintf("%s.%d", wadlib.NUSTMDName, version))
+}
+
+// download downloads the given title, using the TMD with the given name.
+func (c *Client) download(ctx context.Context, titleID wadlib.TitleID, tmdName string) (*wadlib.WAD, error) {
+	// The TMD and ticket are small, and may change between versions.
+	// As such, they are always downloaded in full.
+	tmd, err := c.fetchFile(ctx, titleID, tmdName, -1)
+	if err != nil {
+		return nil, err
+	}
+
+	ticket, err := c.fetchFile(ctx, titleID, wadlib.NUSTicketName, -1)
+	if err != nil {
+		return nil, err
+	}
+
+	wad := wadlib.WAD{}
+	wad.SetKeyProvider(c.KeyProvider)
+	err = wad.LoadNUSMetadata(tmd, ticket)
+	if err != nil {
+		return nil, err
+	}
+
+	titleKey, err := wad.Ticket.GetTitleKey()
+	if err != nil {
+		return nil, err
+	}
+
+	// Data is ordered by position within the TMD.
+	wad.Data = make([]wadlib.WADFile, len(wad.TMD.Contents))
+	for position := range wad.TMD.Contents {
+		record := &wad.TMD.Contents[position]
+		name := wadlib.NUSContentName(record.ID)
+
+		// Contents are stored padded to the AES block size.
+		paddedSize := (record.Size + aes.BlockSize - 1) / aes.BlockSize * aes.BlockSize
+		data, err := c.fetchFile(ctx, titleID, name, int64(paddedSize))
+		if err != nil {
+			return nil, err
+		}
+
+		// Some mirrors pad contents further, which we do not retain.
+		if uint64(len(data)) > paddedSize {
+			data = data[:paddedSize]
+		}
+
+		wad.Data[position] = wadlib.WADFile{
+			Record:  record,
+			RawData: data,
+		}
+
+		// We verify every content prior to downloading the next.
+		status, err := wad.Data[position].Verify(titleKey)
+		if err == nil && status != wadlib.ContentValid {
+			err = &ContentError{
+				ID:     record.ID,
+				Status: status,
+			}
+		}
+		if err != nil {
+			// A corrupted content should not be resumed from.
+			c.discard(titleID, name)
+			return nil, err
+		}
+	}
+
+	return &wad, nil
+}
+
+// titleDirectory returns the directory holding the given title's files beneath our directory.
+func (c *Client) titleDirectory(titleID wadlib.TitleID) string {
+	return filepath.Join(c.Directory, fmt.Sprintf("%016x", uint64(titleID)))
+}
+
+// discard removes the file with the given name for the given title from our directory, if any.
+func (c *Client) discard(titleID wadlib.TitleID, name string) {
+	if c.Directory == "" {
+		return
+	}
+
+	os.Remove(filepath.Join(c.titleDirectory(titleID), name))
+}
+
+// fetchFile downloads the file with the given name for the given title.
+// If size is not negative, data already held within our directory is resumed from until reaching that size.
+func (c *Client) fetchFile(ctx context.Context, titleID wadlib.TitleID, name string, size int64) ([]byte, error) {
+	url := fmt.Sprintf("%s/%016x/%s", c.BaseURL, uint64(titleID), name)
+	if c.Directory == "" {
+		var dst memoryDestination
+		err := c.fetch(ctx, url, &dst, size)
+		if err != nil {
+			return nil, err
+		}
+
+		return dst.Bytes(), nil
+	}
+
+	dir := c.titleDirectory(titleID)
+	err := os.MkdirAll(dir, 0755)
+	if err != nil {
+		return nil, err
+	}
+
+	flags := os.O_RDWR | os.O_CREATE
+	if size < 0 {
+		flags |= os.O_TRUNC
+	}
+
+	file, err := os.OpenFile(filepath.Join(dir, name), flags, 0644)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	dst, err := newFileDestination(file)
+	if err != nil {
+		return nil, err
+	}
+
+	err = c.fetch(ctx, url, dst, size)
+	if err != nil {
+		return nil, err
+	}
+
+	return dst.bytes()
+}
+
+// fetch downloads the given URL to the given destination, resuming from the data it holds.
+// If size is not negative, no request is made once the destination holds at least that amount.
+// Interrupted downloads are resumed up to our amount of retries.
+func (c *Client) fetch(ctx context.Context, url string, dst destination, size int64) error {
+	for attempt := 0; ; attempt++ {
+		if size >= 0 && dst.received() >= size {
+			return nil
+		}
+
+		err := c.fetchOnce(ctx, url, dst)
+		if err == nil {
+			return nil
+		}
+
+		// There is no use retrying requests we were told to stop, or that the server rejected.
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		var statusErr *StatusError
+		if errors.As(err, &statusErr) && statusErr.StatusCode < 500 {
+			return err
+		}
+
+		if attempt >= c.Retries {
+			return err
+		}
+	}
+}
+
+// fetchOnce requests the given URL, appending the response to the given destination.
+func (c *Client) fetchOnce(ctx context.Context, url string, dst destination) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+
+	offset := dst.received()
+	if offset > 0 {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+	}
+
+	resp, err := c.httpClient().Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		// The server does not support ranges, so we must start over.
+		if offset > 0 {
+			err = dst.reset()
+			if err != nil {
+				return err
+			}
+		}
+	case http.StatusPartialContent:
+		var start int64
+		_, err = fmt.Sscanf(resp.Header.Get("Content-Range"), "bytes %d-", &start)
+		if err != nil || start != offset {
+			// We cannot trust what we hold, so we start over on our next attempt.
+			err = dst.reset()
+			if err != nil {
+				return err
+			}
+
+			return ErrUnexpectedRange
+		}
+	default:
+		return &StatusError{
+			URL:        url,
+			StatusCode: resp.StatusCode,
+		}
+	}
+
+	_, err = io.Copy(dst, resp.Body)
+	return err
+}
+
+// destination holds downloaded data, which may be appended to when resuming.
+type destination interface {
+	io.Writer
+	// received returns the amount of data held.
+	received() int64
+	// reset discards all data held.
+	reset() error
+}
+
+// memoryDestination holds downloaded data in memory.
+type memoryDestination struct {
+	bytes.Buffer
+}
+
+func (m *memoryDestination) received() int64 {
+	return int64(m.Len())
+}
+
+func (m *memoryDestination) reset() error {
+	m.Reset()
+	return nil
+}
+
+// fileDestination holds downloaded data within a file, appending to any data present.
+type fileDestination struct {
+	file *os.File
+	size int64
+}
+
+// newFileDestination returns a fileDestination appending to the given file.
+func newFileDestination(file *os.File) (*fileDestination, error) {
+	size, err := file.Seek(0, io.SeekEnd)
+	if err != nil {
+		return nil, err
+	}
+
+	return &fileDestination{
+		file: file,
+		size: size,
+	}, nil
+}
+
+func (f *fileDestination) Write(p []byte) (int, error) {
+	written, err := f.file.Write(p)
+	f.size += int64(written)
+	return written, err
+}
+
+func (f *fileDestination) received() int64 {
+	return f.size
+}
+
+func (f *fileDestination) reset() error {
+	err := f.file.Truncate(0)
+	if err != nil {
+		return err
+	}
+
+	_, err = f.file.Seek(0, io.SeekStart)
+	f.size = 0
+	return err
+}
+
+// bytes returns all data held within this file.
+func (f *fileDestination) bytes() ([]byte, error) {
+	contents := make([]byte, f.size)
+	_, err := f.file.ReadAt(contents, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	return contents, nil
+}
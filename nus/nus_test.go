@@ -0,0 +1,269 @@
+package nus
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/wii-tools/wadlib"
+)
+
+// testServer serves a title built for testing, laid out as NUS is.
+type testServer struct {
+	*httptest.Server
+	// root holds a directory per title ID.
+	root    string
+	wad     *wadlib.WAD
+	titleID wadlib.TitleID
+	// ignoreRange causes every request to be answered in full.
+	ignoreRange bool
+
+	mu sync.Mutex
+	// ranges holds the Range header of every request, alongside the status answered, by path.
+	ranges map[string][]string
+}
+
+func newTestServer(t *testing.T) *testServer {
+	large := make([]byte, 0x30000)
+	for i := range large {
+		large[i] = byte(i * 7)
+	}
+
+	wad, err := wadlib.NewWADBuilder().
+		AddContent([]byte("content zero"), 0x10, wadlib.TitleTypeNormal).
+		AddContent(large, 0x2a, wadlib.TitleTypeNormal).
+		Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = wad.Fakesign()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := &testServer{
+		root:    t.TempDir(),
+		wad:     wad,
+		titleID: wad.TMD.TitleID,
+		ranges:  map[string][]string{},
+	}
+
+	err = wad.ExportNUS(filepath.Join(s.root, fmt.Sprintf("%016x", uint64(s.titleID))))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serve))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func (s *testServer) serve(w http.ResponseWriter, r *http.Request) {
+	requested := r.Header.Get("Range")
+	if s.ignoreRange {
+		r.Header.Del("Range")
+	}
+
+	// Requests are recorded prior to responding, so they are visible once the client is done.
+	recorder := &statusRecorder{
+		ResponseWriter: w,
+		record: func(status int) {
+			s.mu.Lock()
+			defer s.mu.Unlock()
+			s.ranges[r.URL.Path] = append(s.ranges[r.URL.Path], fmt.Sprintf("%s %d", requested, status))
+		},
+	}
+
+	path := filepath.Join(s.root, filepath.FromSlash(strings.TrimPrefix(r.URL.Path, "/")))
+	http.ServeFile(recorder, r, path)
+}
+
+// requests returns the Range header of every request for the given path, alongside the status answered.
+func (s *testServer) requests(path string) []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string{}, s.ranges[path]...)
+}
+
+// contentPath returns the path of the file holding the content with the given ID.
+func (s *testServer) contentPath(id uint32) string {
+	return fmt.Sprintf("/%016x/%08x", uint64(s.titleID), id)
+}
+
+// statusRecorder records the status code answered.
+type statusRecorder struct {
+	http.ResponseWriter
+	record func(status int)
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	r.record(status)
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (s *testServer) newClient(dir string) *Client {
+	c := NewClient(s.URL)
+	c.HTTPClient = s.Client()
+	c.Directory = dir
+	return c
+}
+
+// checkWAD ensures the given WAD is identical to the one served.
+func (s *testServer) checkWAD(t *testing.T, wad *wadlib.WAD) {
+	t.Helper()
+
+	expected, err := s.wad.GetWAD(wadlib.WADTypeCommon)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	actual, err := wad.GetWAD(wadlib.WADTypeCommon)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(actual, expected) {
+		t.Error("downloaded WAD differs from the WAD served")
+	}
+}
+
+func TestDownload(t *testing.T) {
+	s := newTestServer(t)
+	wad, err := s.newClient("").Download(context.Background(), s.titleID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.checkWAD(t, wad)
+
+	wad, err = s.newClient("").DownloadVersion(context.Background(), s.titleID, s.wad.TMD.TitleVersion)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.checkWAD(t, wad)
+}
+
+func TestDownloadMissingVersion(t *testing.T) {
+	s := newTestServer(t)
+	_, err := s.newClient("").DownloadVersion(context.Background(), s.titleID, 0xffff)
+
+	var statusErr *StatusError
+	if !errors.As(err, &statusErr) || statusErr.StatusCode != http.StatusNotFound {
+		t.Errorf("returned %v, expected a StatusError with status 404", err)
+	}
+}
+
+// writePartial writes the first half of the content with the given ID within the given client directory.
+func (s *testServer) writePartial(t *testing.T, dir string, id uint32) {
+	contents, err := ioutil.ReadFile(filepath.Join(s.root, filepath.FromSlash(s.contentPath(id))))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	titleDir := filepath.Join(dir, fmt.Sprintf("%016x", uint64(s.titleID)))
+	err = os.MkdirAll(titleDir, 0755)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = ioutil.WriteFile(filepath.Join(titleDir, wadlib.NUSContentName(id)), contents[:len(contents)/2], 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDownloadResume(t *testing.T) {
+	s := newTestServer(t)
+	dir := t.TempDir()
+	s.writePartial(t, dir, 0x2a)
+
+	wad, err := s.newClient(dir).Download(context.Background(), s.titleID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.checkWAD(t, wad)
+
+	// Only the remaining half should have been requested.
+	expected := fmt.Sprintf("bytes=%d- %d", 0x30000/2, http.StatusPartialContent)
+	if got := s.requests(s.contentPath(0x2a)); len(got) != 1 || got[0] != expected {
+		t.Errorf("requests were %q, expected %q", got, expected)
+	}
+
+	// Contents already present should not be requested again.
+	_, err = s.newClient(dir).Download(context.Background(), s.titleID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := s.requests(s.contentPath(0x2a)); len(got) != 1 {
+		t.Errorf("requests were %q after downloading again, expected no further requests", got)
+	}
+
+	// Our directory should be usable as a NUS directory.
+	wad, err = wadlib.LoadFromNUSDirectory(filepath.Join(dir, fmt.Sprintf("%016x", uint64(s.titleID))))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.checkWAD(t, wad)
+}
+
+func TestDownloadIgnoredRange(t *testing.T) {
+	s := newTestServer(t)
+	s.ignoreRange = true
+	dir := t.TempDir()
+	s.writePartial(t, dir, 0x2a)
+
+	wad, err := s.newClient(dir).Download(context.Background(), s.titleID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.checkWAD(t, wad)
+
+	// The remaining half was requested, but answered in full.
+	expected := fmt.Sprintf("bytes=%d- %d", 0x30000/2, http.StatusOK)
+	if got := s.requests(s.contentPath(0x2a)); len(got) != 1 || got[0] != expected {
+		t.Errorf("requests were %q, expected %q", got, expected)
+	}
+}
+
+func TestDownloadCorrupted(t *testing.T) {
+	s := newTestServer(t)
+	path := filepath.Join(s.root, filepath.FromSlash(s.contentPath(0x2a)))
+	contents, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	contents[0x100] ^= 0xff
+	err = ioutil.WriteFile(path, contents, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+	_, err = s.newClient(dir).Download(context.Background(), s.titleID)
+
+	var contentErr *ContentError
+	if !errors.As(err, &contentErr) || contentErr.ID != 0x2a || contentErr.Status != wadlib.ContentHashMismatch {
+		t.Fatalf("returned %v, expected a hash mismatch for content 0000002a", err)
+	}
+
+	// The corrupted content should be discarded, whereas the valid content is retained.
+	titleDir := filepath.Join(dir, fmt.Sprintf("%016x", uint64(s.titleID)))
+	if _, err = os.Stat(filepath.Join(titleDir, wadlib.NUSContentName(0x2a))); !os.IsNotExist(err) {
+		t.Errorf("corrupted content remains: %v", err)
+	}
+
+	if _, err = os.Stat(filepath.Join(titleDir, wadlib.NUSContentName(0x10))); err != nil {
+		t.Errorf("valid content was not retained: %v", err)
+	}
+}
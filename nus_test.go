@@ -0,0 +1,205 @@
+package wadlib
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// testNUSDirectory exports a WAD from testWAD to a temporary directory, returning both.
+// Its certificate chain is first reordered to XS, CA and then CP.
+func testNUSDirectory(t *testing.T) (*WAD, string) {
+	t.Helper()
+
+	wad := testWAD(t, testWADContents()...)
+	certs, err := wad.GetCertificates()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(certs) != 3 {
+		t.Fatalf("certificate chain holds %d certificates, expected 3", len(certs))
+	}
+
+	wad.CertificateChain, err = BuildCertificateChain([]Certificate{certs[2], certs[0], certs[1]})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+	if err = wad.ExportNUS(dir); err != nil {
+		t.Fatal(err)
+	}
+
+	return wad, dir
+}
+
+func TestNUSRoundTrip(t *testing.T) {
+	wad, dir := testNUSDirectory(t)
+
+	for _, name := range []string{"tmd", "tmd.513", "cetk", "00000000", "00000001", "00000002"} {
+		if _, err := os.Stat(filepath.Join(dir, name)); err != nil {
+			t.Errorf("%s was not exported: %v", name, err)
+		}
+	}
+
+	// The ticket is followed by its signer's certificate, and then the CA's.
+	ticket, err := ioutil.ReadFile(filepath.Join(dir, "cetk"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ticketBytes, err := wad.GetTicket()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ticketCerts, err := ParseCertificateChain(ticket[len(ticketBytes):])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(ticketCerts) != 2 || ticketCerts[0].FullName() != TicketIssuer || ticketCerts[1].GetName() != "CA00000001" {
+		t.Errorf("ticket is followed by %d unexpected certificates", len(ticketCerts))
+	}
+
+	reloaded, err := LoadFromNUSDirectory(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, test := range []struct {
+		name     string
+		getBytes func(*WAD) ([]byte, error)
+	}{
+		{"TMD", (*WAD).GetTMD},
+		{"ticket", (*WAD).GetTicket},
+	} {
+		original, err := test.getBytes(wad)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		loaded, err := test.getBytes(reloaded)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !bytes.Equal(original, loaded) {
+			t.Errorf("%s differs once reloaded", test.name)
+		}
+	}
+
+	// The certificate chain should be ordered as within a retail WAD.
+	certs, err := reloaded.GetCertificates()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var names []string
+	for _, cert := range certs {
+		names = append(names, cert.GetName())
+	}
+
+	expected := []string{"CA00000001", "CP00000004", "XS00000003"}
+	if !reflect.DeepEqual(names, expected) {
+		t.Errorf("certificate chain is ordered %v, expected %v", names, expected)
+	}
+
+	for position, content := range testWADContents() {
+		data, err := reloaded.GetContentAt(position)
+		if err != nil {
+			t.Fatalf("position %d: %v", position, err)
+		}
+
+		if !bytes.Equal(data, content) {
+			t.Errorf("content at position %d differs", position)
+		}
+	}
+}
+
+func TestNUSVersionedTMD(t *testing.T) {
+	wad, dir := testNUSDirectory(t)
+
+	// An older version is present alongside, which would be ordered last by name.
+	older := *wad
+	older.TMD.TitleVersion = 99
+	tmd, err := older.GetTMD()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err = ioutil.WriteFile(filepath.Join(dir, "tmd.99"), tmd, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// "tmd" takes precedence when present.
+	reloaded, err := LoadFromNUSDirectory(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reloaded.TMD.TitleVersion != 513 {
+		t.Errorf("loaded version %d, expected 513", reloaded.TMD.TitleVersion)
+	}
+
+	if err = os.Remove(filepath.Join(dir, "tmd.513")); err != nil {
+		t.Fatal(err)
+	}
+
+	// Otherwise, the highest version is used.
+	if err = os.Rename(filepath.Join(dir, "tmd"), filepath.Join(dir, "tmd.513")); err != nil {
+		t.Fatal(err)
+	}
+
+	reloaded, err = LoadFromNUSDirectory(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reloaded.TMD.TitleVersion != 513 {
+		t.Errorf("loaded version %d, expected 513", reloaded.TMD.TitleVersion)
+	}
+
+	if err = os.Remove(filepath.Join(dir, "tmd.513")); err != nil {
+		t.Fatal(err)
+	}
+
+	reloaded, err = LoadFromNUSDirectory(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reloaded.TMD.TitleVersion != 99 {
+		t.Errorf("loaded version %d, expected 99", reloaded.TMD.TitleVersion)
+	}
+
+	if err = os.Remove(filepath.Join(dir, "tmd.99")); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err = LoadFromNUSDirectory(dir); err != ErrNoTMD {
+		t.Errorf("directory without a TMD returned %v, expected ErrNoTMD", err)
+	}
+}
+
+func TestNUSTruncatedContent(t *testing.T) {
+	_, dir := testNUSDirectory(t)
+
+	// The final content is 0x123 bytes, stored as 0x130.
+	path := filepath.Join(dir, "00000002")
+	if err := os.Truncate(path, 0x120); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := LoadFromNUSDirectory(dir)
+	if !errors.Is(err, ErrTruncated) {
+		t.Errorf("short content returned %v, expected ErrTruncated", err)
+	}
+
+	var parseErr *ParseError
+	if errors.As(err, &parseErr) && parseErr.Section != "content 2" {
+		t.Errorf("error describes %s, expected content 2", parseErr.Section)
+	}
+}
@@ -0,0 +1,142 @@
+package wadlib
+
+import (
+	"context"
+	"runtime"
+	"sort"
+	"sync"
+)
+
+// DecryptedContent holds the decrypted data of a content alongside its record.
+type DecryptedContent struct {
+	Record ContentRecord
+	Data   []byte
+}
+
+// forEachContent calls the given function for every position within w.Data,
+// with up to the given amount of workers at once. If workers is 0 or less,
+// one worker is used per CPU. Upon the first error or the given context
+// being cancelled, no further contents are started and that error is returned.
+func (w *WAD) forEachContent(ctx context.Context, workers int, do func(position int) error) error {
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	positions := make(chan int)
+	var once sync.Once
+	var firstErr error
+	fail := func(err error) {
+		once.Do(func() {
+			firstErr = err
+			cancel()
+		})
+	}
+
+	var wg sync.WaitGroup
+	for worker := 0; worker < workers; worker++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for position := range positions {
+				err := do(position)
+				if err != nil {
+					fail(err)
+				}
+			}
+		}()
+	}
+
+	// Hand out positions until we run out, or are told to stop.
+	var cancelled error
+feed:
+	for position := range w.Data {
+		select {
+		case positions <- position:
+		case <-ctx.Done():
+			cancelled = ctx.Err()
+			break feed
+		}
+	}
+	close(positions)
+	wg.Wait()
+
+	if firstErr != nil {
+		return firstErr
+	}
+	return cancelled
+}
+
+// DecryptAll decrypts every content within this WAD, with up to the given amount of workers at once.
+// If workers is 0 or less, one worker is used per CPU. Results are returned ordered by content index.
+// Contents already being decrypted are finished when the given context is cancelled,
+// but no further contents are started.
+func (w *WAD) DecryptAll(ctx context.Context, workers int) ([]DecryptedContent, error) {
+	titleKey, err := w.Ticket.GetTitleKey()
+	if err != nil {
+		return nil, err
+	}
+
+	// Each worker writes only to its own position.
+	results := make([]DecryptedContent, len(w.Data))
+	err = w.forEachContent(ctx, workers, func(position int) error {
+		data, err := w.Data[position].DecryptData(titleKey)
+		if err != nil {
+			return err
+		}
+
+		results[position] = DecryptedContent{
+			Record: *w.Data[position].Record,
+			Data:   data,
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(results, func(i, j int) bool {
+		return results[i].Record.Index < results[j].Record.Index
+	})
+	return results, nil
+}
+
+// ChangeTitleKeyParallel behaves as ChangeTitleKey, decrypting and re-encrypting contents
+// with up to the given amount of workers at once. If workers is 0 or less, one worker is used per CPU.
+// If the given context is cancelled while decrypting, this WAD is left unchanged.
+// Once re-encryption begins, cancellation is no longer honoured so that all contents match the new key.
+func (w *WAD) ChangeTitleKeyParallel(ctx context.Context, updatedKey [16]byte, workers int) error {
+	oldTitleKey, err := w.Ticket.GetTitleKey()
+	if err != nil {
+		return err
+	}
+
+	// Save existing title data to a separate array, by position.
+	titleData := make([][]byte, len(w.Data))
+	err = w.forEachContent(ctx, workers, func(position int) error {
+		decrypted, err := w.Data[position].DecryptData(oldTitleKey)
+		if err != nil {
+			return err
+		}
+
+		titleData[position] = decrypted
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	// Update our title key.
+	err = w.Ticket.UpdateTitleKey(updatedKey)
+	if err != nil {
+		return err
+	}
+
+	// Encrypt our separate title data.
+	return w.forEachContent(context.Background(), workers, func(position int) error {
+		w.Data[position].UpdateData(titleData[position], updatedKey)
+		return nil
+	})
+}
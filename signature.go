@@ -0,0 +1,347 @@
+package wadlib
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// rootIssuer is the issuer listed by certificates signed by the root certificate.
+const rootIssuer = "Root"
+
+var (
+	ErrNoRootKey            = errors.New("no root key is configured to verify against")
+	ErrSignatureMismatch    = errors.New("signature does not match its contents")
+	ErrUnsupportedSignature = errors.New("signature type cannot be verified")
+	ErrFakesignFailed       = errors.New("unable to find a suitable hash while fakesigning")
+	ErrUnsupportedSigner    = errors.New("signer must hold an RSA-2048 or RSA-4096 key")
+)
+
+// SignatureStatus describes the outcome of verifying a signature.
+type SignatureStatus int
+
+const (
+	// SignatureValid is reported when a signature matches the key of its issuer.
+	SignatureValid SignatureStatus = iota
+	// SignatureFakesigned is reported when a signature has been zeroed and its contents
+	// altered so that their SHA-1 hash begins with a null byte, as is typical for "trucha" signing.
+	SignatureFakesigned
+	// SignatureInvalid is reported when a signature does not match the key of its issuer.
+	SignatureInvalid
+	// SignatureUnverified is reported when the issuer's key is not available to verify against.
+	SignatureUnverified
+)
+
+func (s SignatureStatus) String() string {
+	switch s {
+	case SignatureValid:
+		return "valid"
+	case SignatureFakesigned:
+		return "fakesigned"
+	case SignatureInvalid:
+		return "bad signature"
+	case SignatureUnverified:
+		return "unverified"
+	default:
+		return fmt.Sprintf("SignatureStatus(%d)", int(s))
+	}
+}
+
+// SignatureResult describes the outcome of verifying a single signed object.
+type SignatureResult struct {
+	// Name describes the object verified, such as "Ticket", "TMD" or "Root-CA00000001-XS00000003".
+	Name   string
+	Issuer string
+	Status SignatureStatus
+	// Err describes why a signature could not be verified, if so.
+	Err error
+}
+
+// SignatureReport describes the outcome of verifying all signatures within a WAD.
+type SignatureReport struct {
+	Certificates []SignatureResult
+	Ticket       SignatureResult
+	TMD          SignatureResult
+}
+
+// Valid returns whether all signatures within the report are valid.
+// This includes the CA certificate, so the chain must have been verified against a root key.
+func (r *SignatureReport) Valid() bool {
+	for _, cert := range r.Certificates {
+		if cert.Status != SignatureValid {
+			return false
+		}
+	}
+
+	return r.Ticket.Status == SignatureValid && r.TMD.Status == SignatureValid
+}
+
+// VerifySignatures verifies the signatures of the ticket and TMD against their issuers
+// within the certificate chain, and each certificate within the chain against its own issuer.
+// Certificates issued by the root are verified against RootKey.
+//
+// RootKey is nil by default. Unless it has been set, the CA certificate is reported as SignatureUnverified
+// with ErrNoRootKey, and the report is never Valid, even for a legitimately signed WAD.
+// Results for the ticket, TMD and the certificates issued by the CA are unaffected.
+// To validate the full chain, pass the root's public key to VerifySignaturesWithRoot.
+func (w *WAD) VerifySignatures() (*SignatureReport, error) {
+	return w.VerifySignaturesWithRoot(RootKey)
+}
+
+// VerifySignaturesWithRoot verifies signatures as VerifySignatures does,
+// verifying certificates issued by the root against the given key rather than RootKey.
+// This allows verifying against differing roots, such as those of retail and development consoles, at once.
+func (w *WAD) VerifySignaturesWithRoot(root *rsa.PublicKey) (*SignatureReport, error) {
+	certs, err := w.GetCertificates()
+	if err != nil {
+		return nil, err
+	}
+
+	report := SignatureReport{}
+	for _, cert := range certs {
+		signed, err := cert.getSignedBytes()
+		if err != nil {
+			return nil, err
+		}
+
+		result := verifySignature(cert.SignatureType, cert.Signature, signed, cert.GetIssuer(), certs, root)
+		result.Name = cert.FullName()
+		report.Certificates = append(report.Certificates, result)
+	}
+
+	ticket, err := w.Ticket.getSignedBytes()
+	if err != nil {
+		return nil, err
+	}
+
+	report.Ticket = verifySignature(w.Ticket.SignatureType, w.Ticket.Signature, ticket, nullTerminated(w.Ticket.Issuer[:]), certs, root)
+	report.Ticket.Name = "Ticket"
+
+	tmd, err := w.TMD.getSignedBytes()
+	if err != nil {
+		return nil, err
+	}
+
+	report.TMD = verifySignature(w.TMD.SignatureType, w.TMD.Signature, tmd, nullTerminated(w.TMD.Issuer[:]), certs, root)
+	report.TMD.Name = "TMD"
+
+	return &report, nil
+}
+
+// verifySignature verifies the given signature over the signed contents with the key of the given issuer.
+// Should the issuer be the root, the given root key is used.
+func verifySignature(signatureType SignatureType, signature []byte, signed []byte, issuer string, certs []Certificate, root *rsa.PublicKey) SignatureResult {
+	result := SignatureResult{
+		Issuer: issuer,
+	}
+
+	// A fakesigned object has its signature zeroed.
+	// As IOS compares hashes with strncmp, a hash beginning with a null byte will match.
+	sha := sha1.Sum(signed)
+	if isZeroed(signature) {
+		if sha[0] == 0x00 {
+			result.Status = SignatureFakesigned
+		} else {
+			result.Status = SignatureInvalid
+			result.Err = ErrSignatureMismatch
+		}
+		return result
+	}
+
+	// Only RSA signatures can be verified against the certificates within a chain.
+	var hashType crypto.Hash
+	var hash []byte
+	switch signatureType {
+	case SignatureRSA4096, SignatureRSA2048:
+		hashType = crypto.SHA1
+		hash = sha[:]
+	case SignatureRSA4096SHA256, SignatureRSA2048SHA256:
+		sha256Hash := sha256.Sum256(signed)
+		hashType = crypto.SHA256
+		hash = sha256Hash[:]
+	default:
+		result.Status = SignatureUnverified
+		result.Err = ErrUnsupportedSignature
+		return result
+	}
+
+	// Determine the key we're verifying against.
+	var key *rsa.PublicKey
+	if issuer == rootIssuer {
+		key = root
+		if key == nil {
+			result.Status = SignatureUnverified
+			result.Err = ErrNoRootKey
+			return result
+		}
+	} else {
+		cert, err := FindCertificate(certs, issuer)
+		if err != nil {
+			result.Status = SignatureUnverified
+			result.Err = err
+			return result
+		}
+
+		key, err = cert.RSAPublicKey()
+		if err != nil {
+			result.Status = SignatureUnverified
+			result.Err = err
+			return result
+		}
+	}
+
+	err := rsa.VerifyPKCS1v15(key, hashType, hash, signature)
+	if err != nil {
+		result.Status = SignatureInvalid
+		result.Err = ErrSignatureMismatch
+		return result
+	}
+
+	result.Status = SignatureValid
+	return result
+}
+
+// readSignature reads a signature type, its signature and the padding following from the given reader.
+func readSignature(r io.Reader) (SignatureType, []byte, error) {
+	var signatureType SignatureType
+	err := binary.Read(r, binary.BigEndian, &signatureType)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	// The signature's length differs per its type.
+	signatureSize, signaturePadding, err := signatureType.signatureSizes()
+	if err != nil {
+		return 0, nil, err
+	}
+
+	signature := make([]byte, signatureSize+signaturePadding)
+	_, err = io.ReadFull(r, signature)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	return signatureType, signature[:signatureSize], nil
+}
+
+// writeSignature writes the given signature type, its signature and the padding following.
+func writeSignature(w *bytes.Buffer, signatureType SignatureType, signature []byte) error {
+	signatureSize, signaturePadding, err := signatureType.signatureSizes()
+	if err != nil {
+		return err
+	}
+
+	if len(signature) != signatureSize {
+		return fmt.Errorf("signature should be %d bytes for signature type %08x", signatureSize, uint32(signatureType))
+	}
+
+	err = binary.Write(w, binary.BigEndian, signatureType)
+	if err != nil {
+		return err
+	}
+
+	w.Write(signature)
+	w.Write(make([]byte, signaturePadding))
+	return nil
+}
+
+// zeroSignature returns an empty signature sized as appropriate for the given signature type.
+func zeroSignature(signatureType SignatureType) ([]byte, error) {
+	signatureSize, _, err := signatureType.signatureSizes()
+	if err != nil {
+		return nil, err
+	}
+
+	return make([]byte, signatureSize), nil
+}
+
+// isZeroed returns whether all bytes within the given slice are null.
+func isZeroed(source []byte) bool {
+	for _, value := range source {
+		if value != 0x00 {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Fakesign fakesigns both the ticket and TMD within the current WAD.
+// This is necessary after modifying either, such as via UpdateContent or ChangeTitleKey.
+func (w *WAD) Fakesign() error {
+	err := w.Ticket.Fakesign()
+	if err != nil {
+		return err
+	}
+
+	return w.TMD.Fakesign()
+}
+
+// signContents signs the SHA-1 hash of the given contents with the given signer.
+// The signature type is determined by the size of the signer's RSA key.
+func signContents(signer crypto.Signer, signed []byte) (SignatureType, []byte, error) {
+	signatureType, err := signatureTypeFor(signer)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	hash := sha1.Sum(signed)
+	signature, err := signer.Sign(rand.Reader, hash[:], crypto.SHA1)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	return signatureType, signature, nil
+}
+
+// signatureTypeFor returns the signature type a signer will produce.
+func signatureTypeFor(signer crypto.Signer) (SignatureType, error) {
+	key, ok := signer.Public().(*rsa.PublicKey)
+	if !ok {
+		return 0, ErrUnsupportedSigner
+	}
+
+	switch key.Size() {
+	case 0x200:
+		return SignatureRSA4096, nil
+	case 0x100:
+		return SignatureRSA2048, nil
+	default:
+		return 0, ErrUnsupportedSigner
+	}
+}
+
+// setIssuer copies the given issuer into an issuer field, ensuring it fits.
+func setIssuer(field *[64]byte, issuer string) error {
+	// We require a null terminator to remain.
+	if len(issuer) >= len(field) {
+		return fmt.Errorf("issuer %q is too long", issuer)
+	}
+
+	*field = [64]byte{}
+	copy(field[:], issuer)
+	return nil
+}
+
+// bruteForceHash alters the two bytes at the given offset within the signed contents
+// until their SHA-1 hash begins with a null byte, as required for a "trucha" signature.
+// The chosen value is returned so that it may be stored within its originating structure.
+func bruteForceHash(signed []byte, offset int) (uint16, error) {
+	for attempt := 0; attempt <= 0xffff; attempt++ {
+		binary.BigEndian.PutUint16(signed[offset:], uint16(attempt))
+
+		hash := sha1.Sum(signed)
+		if hash[0] == 0x00 {
+			return uint16(attempt), nil
+		}
+	}
+
+	return 0, ErrFakesignFailed
+}
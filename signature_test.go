@@ -0,0 +1,125 @@
+package wadlib
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"sync"
+	"testing"
+)
+
+// testSignedWAD returns a WAD signed by a freshly generated chain, alongside the root key of that chain.
+func testSignedWAD(t *testing.T) (*WAD, *rsa.PublicKey) {
+	var keys [4]*rsa.PrivateKey
+	for index := range keys {
+		key, err := rsa.GenerateKey(rand.Reader, 2048)
+		if err != nil {
+			t.Fatal(err)
+		}
+		keys[index] = key
+	}
+	root, ca, cp, xs := keys[0], keys[1], keys[2], keys[3]
+
+	wad, err := NewWADBuilder().AddContent([]byte("content"), 0, TitleTypeNormal).Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	wad.CertificateChain, err = GenerateCertificateChain(root, ca, cp, xs)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err = wad.Ticket.Sign(xs, TicketIssuer); err != nil {
+		t.Fatal(err)
+	}
+
+	if err = wad.TMD.Sign(cp, TMDIssuer); err != nil {
+		t.Fatal(err)
+	}
+
+	return wad, &root.PublicKey
+}
+
+func TestVerifySignaturesWithRoot(t *testing.T) {
+	retail, retailRoot := testSignedWAD(t)
+	development, developmentRoot := testSignedWAD(t)
+
+	// Verifying against differing roots at once must not interfere.
+	var wg sync.WaitGroup
+	for attempt := 0; attempt < 8; attempt++ {
+		for _, test := range []struct {
+			wad   *WAD
+			root  *rsa.PublicKey
+			valid bool
+		}{
+			{retail, retailRoot, true},
+			{development, developmentRoot, true},
+			{retail, developmentRoot, false},
+		} {
+			wg.Add(1)
+			go func(wad *WAD, root *rsa.PublicKey, valid bool) {
+				defer wg.Done()
+
+				report, err := wad.VerifySignaturesWithRoot(root)
+				if err != nil {
+					t.Error(err)
+					return
+				}
+
+				if report.Valid() != valid {
+					t.Errorf("report is valid: %t, expected %t", report.Valid(), valid)
+				}
+			}(test.wad, test.root, test.valid)
+		}
+	}
+	wg.Wait()
+
+	// Without a root key, the CA certificate cannot be verified.
+	report, err := retail.VerifySignaturesWithRoot(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if report.Certificates[0].Status != SignatureUnverified || report.Certificates[0].Err != ErrNoRootKey {
+		t.Errorf("CA certificate is %s with %v, expected unverified with ErrNoRootKey", report.Certificates[0].Status, report.Certificates[0].Err)
+	}
+}
+
+func TestVerifySignaturesDefaultRoot(t *testing.T) {
+	wad, root := testSignedWAD(t)
+
+	// No root key is present by default, so the chain cannot be fully validated.
+	report, err := wad.VerifySignatures()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if report.Valid() {
+		t.Error("report is valid without a root key")
+	}
+
+	if report.Certificates[0].Status != SignatureUnverified || report.Certificates[0].Err != ErrNoRootKey {
+		t.Errorf("CA certificate is %s with %v, expected unverified with ErrNoRootKey", report.Certificates[0].Status, report.Certificates[0].Err)
+	}
+
+	for _, result := range append(report.Certificates[1:], report.Ticket, report.TMD) {
+		if result.Status != SignatureValid {
+			t.Errorf("%s is %s, expected valid", result.Name, result.Status)
+		}
+	}
+
+	// Setting RootKey permits validating the chain in full.
+	defer func(previous *rsa.PublicKey) {
+		RootKey = previous
+	}(RootKey)
+	RootKey = root
+
+	report, err = wad.VerifySignatures()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !report.Valid() {
+		t.Errorf("report is not valid with the root key set: %+v", report)
+	}
+}
@@ -2,18 +2,45 @@ package wadlib
 
 import (
 	"bytes"
+	"crypto"
 	"crypto/aes"
 	"crypto/cipher"
+	"crypto/sha1"
 	"encoding/binary"
+	"errors"
+	"fmt"
 	"io/ioutil"
+	"time"
 )
 
-// Ticket defines the binary structure of a given ticket file.
+var (
+	ErrContentAccessOutOfRange = errors.New("content index cannot be described by the ticket's access mask")
+	ErrNotPersonalized         = errors.New("ticket is not personalized")
+)
+
+// ticketFakesignOffset is the offset of the two unused bytes within a ticket's signed contents
+// altered while fakesigning. They immediately precede the ticket's time limits.
+// With an RSA-2048 signature, this is 0x262 within the ticket.
+const ticketFakesignOffset = 0x122
+
+// Ticket defines the structure of a given ticket file.
 type Ticket struct {
 	SignatureType SignatureType
-	Signature     [256]byte
-	// Signature padding to nearest 64 bytes
-	_                [60]byte
+	// Signature is sized as appropriate for the signature type,
+	// and is followed by padding to the nearest 64 bytes.
+	Signature []byte
+	BinaryTicket
+	// V1 holds the sections following version 1 tickets, as determined by their file version.
+	// It is nil for version 0 tickets.
+	V1 *TicketV1
+	// keys provides the common key used to encrypt the title key.
+	// If nil, DefaultKeyProvider is used.
+	keys KeyProvider
+}
+
+// BinaryTicket defines the binary structure of a ticket following its signature.
+// This is the portion of the ticket covered by its signature.
+type BinaryTicket struct {
 	Issuer           [64]byte
 	ECDHData         [60]byte
 	FileVersion      uint8
@@ -23,46 +50,141 @@ type Ticket struct {
 	Padding          byte
 	TicketID         uint64
 	ConsoleID        uint32
-	TitleID          uint64
+	TitleID          TitleID
 	SystemAccessMask [2]uint8
 	TitleVersion     uint16
 	// WiiBrew describes this as the "Permitted Titles Mask".
 	// estypes.h does not agree. We'll use the official description.
 	AccessTitleID   uint32
 	AccessTitleMask uint32
-	LicenseType     uint8
+	LicenseType     ESLicenseType
 	KeyType         KeyType
-	Unknown         [114]byte
+	Unknown         [48]byte
+	// ContentAccessMask holds a bit for every content index this ticket permits access to.
+	// Consider CanAccess and SetAccess.
+	ContentAccessMask [64]byte
+	// FakesignPadding is unused, and is typically altered while fakesigning.
+	FakesignPadding uint16
 	TimeLimits      [8]TimeLimitEntry
 }
 
+// LimitCode describes the type of limit imposed by a time limit entry.
+// These are named per estypes.h.
+type LimitCode uint32
+
+const (
+	LimitNone LimitCode = iota
+	// LimitDuration limits usage of the title to the given amount of seconds.
+	LimitDuration
+	// LimitAbsoluteTime permits usage of the title until the given time.
+	LimitAbsoluteTime
+	// LimitNumberOfTitles limits the amount of titles usable.
+	LimitNumberOfTitles
+	// LimitNumberOfLaunches limits the title to the given amount of launches.
+	LimitNumberOfLaunches
+	// LimitElapsedTime limits usage of the title to the given amount of seconds
+	// from its first launch.
+	LimitElapsedTime
+)
+
+func (c LimitCode) String() string {
+	switch c {
+	case LimitNone:
+		return "none"
+	case LimitDuration:
+		return "duration"
+	case LimitAbsoluteTime:
+		return "absolute time"
+	case LimitNumberOfTitles:
+		return "number of titles"
+	case LimitNumberOfLaunches:
+		return "number of launches"
+	case LimitElapsedTime:
+		return "elapsed time"
+	default:
+		return fmt.Sprintf("LimitCode(%d)", uint32(c))
+	}
+}
+
 // TimeLimitEntry holds a time limit entry for a title.
 type TimeLimitEntry struct {
-	// It's unknown what code represents.
-	Code uint32
-	// Each limit is in seconds.
+	Code LimitCode
+	// Limit is in seconds for time-based limits, a Unix timestamp
+	// for LimitAbsoluteTime, or a count otherwise.
 	Limit uint32
 }
 
-// selectCommonKey determines the proper key based on the index.
-func (t *Ticket) selectCommonKey() [16]byte {
-	switch t.KeyType {
-	case KeyTypeCommon:
-		return CommonKey
-	case KeyTypeKorean:
-		return KoreanKey
-	case KeyTypevWii:
-		return WiiUvWiiKey
-	default:
-		// Some WAD fakesigning utilities use an index outside of this list.
-		// We assume the WAD is using the common key regardless.
-		return CommonKey
+// Duration returns this entry's limit as a duration.
+// It is only meaningful for LimitDuration and LimitElapsedTime.
+func (e TimeLimitEntry) Duration() time.Duration {
+	return time.Duration(e.Limit) * time.Second
+}
+
+// Time returns this entry's limit as a time.
+// It is only meaningful for LimitAbsoluteTime.
+func (e TimeLimitEntry) Time() time.Time {
+	return time.Unix(int64(e.Limit), 0).UTC()
+}
+
+// GetTimeLimits returns the time limits in use by this ticket.
+// Unused entries are omitted, and permanent licenses typically have none.
+func (t *Ticket) GetTimeLimits() []TimeLimitEntry {
+	var limits []TimeLimitEntry
+	for _, entry := range t.TimeLimits {
+		if entry.Code != LimitNone {
+			limits = append(limits, entry)
+		}
+	}
+
+	return limits
+}
+
+// CanAccess returns whether this ticket permits access to the content with the given index.
+// Indexes beyond those described by the access mask are not permitted.
+// Version 1 tickets may additionally describe access via TicketSection.ContentIndexRecords.
+func (t *Ticket) CanAccess(index uint16) bool {
+	if int(index) >= len(t.ContentAccessMask)*8 {
+		return false
+	}
+
+	// As with content index records, bits begin from the least significant bit of each byte.
+	return t.ContentAccessMask[index/8]&(1<<(index%8)) != 0
+}
+
+// SetAccess permits or forbids access to the content with the given index.
+// Note that the ticket must be signed again afterwards.
+func (t *Ticket) SetAccess(index uint16, allowed bool) error {
+	if int(index) >= len(t.ContentAccessMask)*8 {
+		return ErrContentAccessOutOfRange
+	}
+
+	if allowed {
+		t.ContentAccessMask[index/8] |= 1 << (index % 8)
+	} else {
+		t.ContentAccessMask[index/8] &^= 1 << (index % 8)
+	}
+	return nil
+}
+
+// selectCommonKey determines the proper key based on the index, via this ticket's key provider.
+// Some WAD fakesigning utilities use an index outside of the known types.
+// Rather than assuming these use the common key, an error is returned.
+func (t *Ticket) selectCommonKey() ([16]byte, error) {
+	keys := t.keys
+	if keys == nil {
+		keys = DefaultKeyProvider
 	}
+
+	return keys.GetCommonKey(t.KeyType)
 }
 
-func (t *Ticket) GetTitleKey() [16]byte {
+// GetTitleKey returns the decrypted title key for this ticket.
+func (t *Ticket) GetTitleKey() ([16]byte, error) {
 	// Use the appropriate common key per this ticket.
-	key := t.selectCommonKey()
+	key, err := t.selectCommonKey()
+	if err != nil {
+		return [16]byte{}, err
+	}
 
 	// It should not be possible for our key not to be 16 bytes.
 	block, err := aes.NewCipher(key[:])
@@ -72,7 +194,7 @@ func (t *Ticket) GetTitleKey() [16]byte {
 
 	// The specified title ID is used as the IV.
 	var titleId [16]byte
-	binary.BigEndian.PutUint64(titleId[:], t.TitleID)
+	binary.BigEndian.PutUint64(titleId[:], uint64(t.TitleID))
 
 	// The resulting decrypted key is 16 bytes in length as well.
 	blockMode := cipher.NewCBCDecrypter(block, titleId[:])
@@ -84,15 +206,86 @@ func (t *Ticket) GetTitleKey() [16]byte {
 	// Set this decrypted key to what we have stored.
 	var titleKey [16]byte
 	copy(titleKey[:], decryptedKey)
-	return titleKey
+	return titleKey, nil
+}
+
+// IsPersonalized returns whether this ticket is bound to a specific console.
+// The title key of a personalized ticket is additionally encrypted with a key derived
+// via ECDH from the console's private key and the public key held in ECDHData.
+func (t *Ticket) IsPersonalized() bool {
+	var zero [60]byte
+	return t.ConsoleID != 0 && t.ECDHData != zero
+}
+
+// getPersonalizedTitleKey returns the title key of this personalized ticket
+// as encrypted with the common key, as if the ticket were never personalized.
+func (t *Ticket) getPersonalizedTitleKey(privateKey [30]byte) ([16]byte, error) {
+	var titleKey [16]byte
+	if !t.IsPersonalized() {
+		return titleKey, ErrNotPersonalized
+	}
+
+	// The AES key is the first 16 bytes of the SHA-1 hash of the shared point's x coordinate.
+	shared, err := eccSharedSecret(privateKey, t.ECDHData)
+	if err != nil {
+		return titleKey, err
+	}
+	sharedHash := sha1.Sum(shared)
+
+	// It should not be possible for our key not to be 16 bytes.
+	block, err := aes.NewCipher(sharedHash[:16])
+	if err != nil {
+		panic(err)
+	}
+
+	// Unlike with the common key, the ticket ID is used as the IV.
+	var ticketId [16]byte
+	binary.BigEndian.PutUint64(ticketId[:], t.TicketID)
+
+	blockMode := cipher.NewCBCDecrypter(block, ticketId[:])
+	blockMode.CryptBlocks(titleKey[:], t.TitleKey[:])
+	return titleKey, nil
+}
+
+// GetPersonalizedTitleKey returns the decrypted title key of this personalized ticket,
+// given the private ECC key of the console it is bound to.
+// This key can be found within the console's OTP, or keys.bin dumped by BootMii.
+func (t *Ticket) GetPersonalizedTitleKey(privateKey [30]byte) ([16]byte, error) {
+	encryptedKey, err := t.getPersonalizedTitleKey(privateKey)
+	if err != nil {
+		return [16]byte{}, err
+	}
+
+	// The remaining layer is the common key, as with any other ticket.
+	common := *t
+	common.TitleKey = encryptedKey
+	return common.GetTitleKey()
+}
+
+// Depersonalize converts this personalized ticket into a common ticket usable on any console,
+// given the private ECC key of the console it is bound to.
+// Note that its signature will no longer be valid. Consider using Fakesign afterwards.
+func (t *Ticket) Depersonalize(privateKey [30]byte) error {
+	encryptedKey, err := t.getPersonalizedTitleKey(privateKey)
+	if err != nil {
+		return err
+	}
+
+	t.TitleKey = encryptedKey
+	t.ConsoleID = 0
+	t.ECDHData = [60]byte{}
+	return nil
 }
 
 // UpdateTitleKey updates the key for the given ticket.
 // Note that this will not re-encrypt existing data for the WAD.
 // Consider using WAD.ChangeTitleKey to re-encrypt instead, where possible.
-func (t *Ticket) UpdateTitleKey(updated [16]byte) {
+func (t *Ticket) UpdateTitleKey(updated [16]byte) error {
 	// Use the appropriate common key per this ticket.
-	key := t.selectCommonKey()
+	key, err := t.selectCommonKey()
+	if err != nil {
+		return err
+	}
 
 	// It should not be possible for our key not to be 16 bytes.
 	block, err := aes.NewCipher(key[:])
@@ -102,7 +295,7 @@ func (t *Ticket) UpdateTitleKey(updated [16]byte) {
 
 	// The specified title ID is used as the IV.
 	var titleId [16]byte
-	binary.BigEndian.PutUint64(titleId[:], t.TitleID)
+	binary.BigEndian.PutUint64(titleId[:], uint64(t.TitleID))
 
 	// The resulting encrypted key is 16 bytes in length as well.
 	blockMode := cipher.NewCBCEncrypter(block, titleId[:])
@@ -115,30 +308,60 @@ func (t *Ticket) UpdateTitleKey(updated [16]byte) {
 	var newTitleKey [16]byte
 	copy(newTitleKey[:], encryptedKey)
 	t.TitleKey = newTitleKey
+	return nil
 }
 
 // LoadTicket loads the given bytes from source into the Ticket for the current WAD.
 func (w *WAD) LoadTicket(source []byte) error {
 	var ticket Ticket
 	loadingBuf := bytes.NewBuffer(source)
-	err := binary.Read(loadingBuf, binary.BigEndian, &ticket)
+
+	// The signature's length differs per its type.
+	var err error
+	ticket.SignatureType, ticket.Signature, err = readSignature(loadingBuf)
 	if err != nil {
-		return err
+		return newParseError("ticket signature", 0, err)
+	}
+
+	offset := int64(len(source) - loadingBuf.Len())
+	err = binary.Read(loadingBuf, binary.BigEndian, &ticket.BinaryTicket)
+	if err != nil {
+		return newParseError("ticket", offset, err)
+	}
+
+	// Version 1 tickets hold further sections following.
+	if ticket.FileVersion == 1 {
+		offset = int64(len(source) - loadingBuf.Len())
+		ticket.V1, err = loadTicketV1(loadingBuf.Bytes())
+		if err != nil {
+			return withOffset("ticket v1", offset, err)
+		}
 	}
 
+	// Retain the key provider set on this WAD, if any.
+	ticket.keys = w.Ticket.keys
 	w.Ticket = ticket
 	return nil
 }
 
 // GetTicket returns the bytes of a given Ticket within the current WAD.
 func (w *WAD) GetTicket() ([]byte, error) {
-	ticket := w.Ticket
+	return w.Ticket.getBytes()
+}
 
+// getBytes returns the bytes of this Ticket.
+func (t *Ticket) getBytes() ([]byte, error) {
 	var tmp bytes.Buffer
-	err := binary.Write(&tmp, binary.BigEndian, ticket)
+	err := writeSignature(&tmp, t.SignatureType, t.Signature)
+	if err != nil {
+		return nil, err
+	}
+
+	signed, err := t.getSignedBytes()
 	if err != nil {
 		return nil, err
 	}
+	tmp.Write(signed)
 
 	contents, err := ioutil.ReadAll(&tmp)
 	if err != nil {
@@ -147,3 +370,65 @@ func (w *WAD) GetTicket() ([]byte, error) {
 
 	return contents, nil
 }
+
+// getSignedBytes returns the portion of this Ticket covered by its signature.
+// This begins at its issuer, immediately following the signature and its padding.
+// For version 1 tickets, this extends through all sections following.
+func (t *Ticket) getSignedBytes() ([]byte, error) {
+	var tmp bytes.Buffer
+	err := binary.Write(&tmp, binary.BigEndian, t.BinaryTicket)
+	if err != nil {
+		return nil, err
+	}
+
+	if t.FileVersion == 1 && t.V1 != nil {
+		sections, err := t.V1.getBytes()
+		if err != nil {
+			return nil, err
+		}
+		tmp.Write(sections)
+	}
+
+	return tmp.Bytes(), nil
+}
+
+// Fakesign zeroes the signature of this ticket, and alters two unused bytes
+// until the SHA-1 hash of its signed contents begins with a null byte.
+// Consoles with a patched IOS will accept the resulting ticket.
+func (t *Ticket) Fakesign() error {
+	signature, err := zeroSignature(t.SignatureType)
+	if err != nil {
+		return err
+	}
+	t.Signature = signature
+
+	signed, err := t.getSignedBytes()
+	if err != nil {
+		return err
+	}
+
+	value, err := bruteForceHash(signed, ticketFakesignOffset)
+	if err != nil {
+		return err
+	}
+
+	t.FakesignPadding = value
+	return nil
+}
+
+// Sign signs this ticket with the given RSA signer, listing the given issuer.
+// The issuer should be the full name of the signer's certificate, such as TicketIssuer.
+func (t *Ticket) Sign(signer crypto.Signer, issuer string) error {
+	err := setIssuer(&t.Issuer, issuer)
+	if err != nil {
+		return err
+	}
+
+	signed, err := t.getSignedBytes()
+	if err != nil {
+		return err
+	}
+
+	t.SignatureType, t.Signature, err = signContents(signer, signed)
+	return err
+}
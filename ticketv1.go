@@ -0,0 +1,236 @@
+package wadlib
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+)
+
+var (
+	ErrInvalidTicketSection = errors.New("ticket section is malformed")
+)
+
+// TicketSectionType describes the type of records held within a section of a version 1 ticket.
+type TicketSectionType uint16
+
+const (
+	TicketSectionPermanent          TicketSectionType = 1
+	TicketSectionSubscription       TicketSectionType = 2
+	TicketSectionContent            TicketSectionType = 3
+	TicketSectionContentConsumption TicketSectionType = 4
+	TicketSectionAccessTitle        TicketSectionType = 5
+)
+
+// ticketV1HeaderSize and ticketV1SectionHeaderSize are the sizes of
+// the version 1 header and each section header, respectively.
+const (
+	ticketV1HeaderSize        = 0x14
+	ticketV1SectionHeaderSize = 0x14
+)
+
+// TicketV1 describes the data following the body of a version 1 ticket.
+// It holds a list of sections, each containing records of a given type.
+type TicketV1 struct {
+	HeaderVersion uint16
+	Flags         uint32
+	Sections      []TicketSection
+}
+
+// TicketSection describes a single section within a version 1 ticket.
+type TicketSection struct {
+	Type  TicketSectionType
+	Flags uint16
+	// RecordSize is the size of every record within this section.
+	RecordSize uint32
+	// Records holds each record within this section as bytes.
+	// For content sections, consider ContentIndexRecords.
+	Records [][]byte
+}
+
+// ContentIndexRecord describes access to a range of 1024 contents, as held within a content section.
+type ContentIndexRecord struct {
+	// Offset is the first content index described by this record.
+	Offset uint32
+	// AccessMask holds a bit for every content index, from the least significant bit of each byte onwards.
+	AccessMask [128]byte
+}
+
+// binaryTicketV1Header describes the byte-level format of the header for a version 1 ticket.
+type binaryTicketV1Header struct {
+	HeaderVersion     uint16
+	HeaderSize        uint16
+	TicketSize        uint32
+	SectionsOffset    uint32
+	NumberOfSections  uint16
+	SectionHeaderSize uint16
+	Flags             uint32
+}
+
+// binaryTicketSectionHeader describes the byte-level format of a section header within a version 1 ticket.
+type binaryTicketSectionHeader struct {
+	Offset          uint32
+	NumberOfRecords uint32
+	RecordSize      uint32
+	SectionSize     uint32
+	Type            TicketSectionType
+	Flags           uint16
+}
+
+// loadTicketV1 parses the given data following the body of a version 1 ticket.
+func loadTicketV1(source []byte) (*TicketV1, error) {
+	var header binaryTicketV1Header
+	err := binary.Read(bytes.NewReader(source), binary.BigEndian, &header)
+	if err != nil {
+		return nil, newParseError("ticket v1 header", 0, err)
+	}
+
+	if int(header.TicketSize) > len(source) {
+		return nil, newParseError("ticket v1 header", 0, ErrSectionOverflow)
+	}
+	source = source[:header.TicketSize]
+
+	ticket := TicketV1{
+		HeaderVersion: header.HeaderVersion,
+		Flags:         header.Flags,
+	}
+
+	for index := 0; index < int(header.NumberOfSections); index++ {
+		offset := int64(header.SectionsOffset) + int64(index)*int64(header.SectionHeaderSize)
+		if offset+ticketV1SectionHeaderSize > int64(len(source)) {
+			return nil, newParseError("ticket v1 section header", offset, ErrSectionOverflow)
+		}
+
+		var sectionHeader binaryTicketSectionHeader
+		err = binary.Read(bytes.NewReader(source[offset:]), binary.BigEndian, &sectionHeader)
+		if err != nil {
+			return nil, newParseError("ticket v1 section header", offset, err)
+		}
+
+		// Each section's records must lie within the ticket.
+		sectionStart := int64(sectionHeader.Offset)
+		sectionEnd := sectionStart + int64(sectionHeader.NumberOfRecords)*int64(sectionHeader.RecordSize)
+		if sectionEnd > int64(len(source)) {
+			return nil, newParseError("ticket v1 section", sectionStart, ErrSectionOverflow)
+		}
+
+		section := TicketSection{
+			Type:       sectionHeader.Type,
+			Flags:      sectionHeader.Flags,
+			RecordSize: sectionHeader.RecordSize,
+		}
+		for record := sectionStart; record < sectionEnd; record += int64(sectionHeader.RecordSize) {
+			section.Records = append(section.Records, append([]byte{}, source[record:record+int64(sectionHeader.RecordSize)]...))
+		}
+
+		ticket.Sections = append(ticket.Sections, section)
+	}
+
+	return &ticket, nil
+}
+
+// getBytes returns the bytes of this version 1 ticket data.
+// Section headers immediately follow the header, with each section's records following thereafter.
+func (t *TicketV1) getBytes() ([]byte, error) {
+	sectionsOffset := uint32(ticketV1HeaderSize)
+	recordsOffset := sectionsOffset + uint32(len(t.Sections))*ticketV1SectionHeaderSize
+
+	var sectionHeaders bytes.Buffer
+	var records bytes.Buffer
+	for _, section := range t.Sections {
+		sectionSize := uint32(0)
+		for _, record := range section.Records {
+			if uint32(len(record)) != section.RecordSize {
+				return nil, ErrInvalidTicketSection
+			}
+
+			records.Write(record)
+			sectionSize += section.RecordSize
+		}
+
+		err := binary.Write(&sectionHeaders, binary.BigEndian, binaryTicketSectionHeader{
+			Offset:          recordsOffset,
+			NumberOfRecords: uint32(len(section.Records)),
+			RecordSize:      section.RecordSize,
+			SectionSize:     sectionSize,
+			Type:            section.Type,
+			Flags:           section.Flags,
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		recordsOffset += sectionSize
+	}
+
+	var tmp bytes.Buffer
+	err := binary.Write(&tmp, binary.BigEndian, binaryTicketV1Header{
+		HeaderVersion:     t.HeaderVersion,
+		HeaderSize:        ticketV1HeaderSize,
+		TicketSize:        recordsOffset,
+		SectionsOffset:    sectionsOffset,
+		NumberOfSections:  uint16(len(t.Sections)),
+		SectionHeaderSize: ticketV1SectionHeaderSize,
+		Flags:             t.Flags,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	tmp.Write(sectionHeaders.Bytes())
+	tmp.Write(records.Bytes())
+	return tmp.Bytes(), nil
+}
+
+// ContentIndexRecords returns the records within a content section.
+func (s *TicketSection) ContentIndexRecords() ([]ContentIndexRecord, error) {
+	if s.Type != TicketSectionContent {
+		return nil, ErrInvalidTicketSection
+	}
+
+	var records []ContentIndexRecord
+	for _, contents := range s.Records {
+		var record ContentIndexRecord
+		err := binary.Read(bytes.NewReader(contents), binary.BigEndian, &record)
+		if err == io.ErrUnexpectedEOF || err == io.EOF {
+			return nil, ErrInvalidTicketSection
+		} else if err != nil {
+			return nil, err
+		}
+
+		records = append(records, record)
+	}
+
+	return records, nil
+}
+
+// NewContentIndexSection returns a content section holding the given records.
+func NewContentIndexSection(records []ContentIndexRecord) (TicketSection, error) {
+	section := TicketSection{
+		Type:       TicketSectionContent,
+		RecordSize: uint32(binary.Size(ContentIndexRecord{})),
+	}
+
+	for _, record := range records {
+		var tmp bytes.Buffer
+		err := binary.Write(&tmp, binary.BigEndian, record)
+		if err != nil {
+			return section, err
+		}
+
+		section.Records = append(section.Records, tmp.Bytes())
+	}
+
+	return section, nil
+}
+
+// CanAccessContent returns whether this record permits access to the given content index.
+// Indexes outside of the range described by this record are not permitted.
+func (r *ContentIndexRecord) CanAccessContent(index uint32) bool {
+	if index < r.Offset || index-r.Offset >= uint32(len(r.AccessMask))*8 {
+		return false
+	}
+
+	bit := index - r.Offset
+	return r.AccessMask[bit/8]&(1<<(bit%8)) != 0
+}
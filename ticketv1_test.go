@@ -0,0 +1,129 @@
+package wadlib
+
+import (
+	"bytes"
+	"encoding/binary"
+	"reflect"
+	"testing"
+)
+
+// testV1Ticket returns a version 1 ticket with a content section and a permanent section.
+// The content section permits access to indexes 0, 5 and 1027.
+func testV1Ticket(t *testing.T) *Ticket {
+	t.Helper()
+
+	wad := WAD{}
+	if err := wad.LoadTicket(TicketTemplate); err != nil {
+		t.Fatal(err)
+	}
+
+	first := ContentIndexRecord{Offset: 0}
+	first.AccessMask[0] = 1<<0 | 1<<5
+	second := ContentIndexRecord{Offset: 1024}
+	second.AccessMask[0] = 1 << 3
+
+	contentSection, err := NewContentIndexSection([]ContentIndexRecord{first, second})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ticket := wad.Ticket
+	ticket.FileVersion = 1
+	ticket.V1 = &TicketV1{
+		HeaderVersion: 1,
+		Flags:         0x10,
+		Sections: []TicketSection{
+			contentSection,
+			{
+				Type:       TicketSectionPermanent,
+				Flags:      0x1,
+				RecordSize: 8,
+				Records:    [][]byte{{0, 1, 2, 3, 4, 5, 6, 7}},
+			},
+		},
+	}
+	return &ticket
+}
+
+func TestTicketV1RoundTrip(t *testing.T) {
+	ticket := testV1Ticket(t)
+	source, err := ticket.getBytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The header and both section headers should immediately follow the ticket's body.
+	// Two content index records of 0x84 bytes and one permanent record of 8 bytes follow them, totalling 0x14c bytes.
+	v1 := source[0x140+binary.Size(BinaryTicket{}):]
+	expectedHeader := []byte{
+		0x00, 0x01, 0x00, 0x14,
+		0x00, 0x00, 0x01, 0x4c,
+		0x00, 0x00, 0x00, 0x14,
+		0x00, 0x02, 0x00, 0x14,
+		0x00, 0x00, 0x00, 0x10,
+	}
+	if len(v1) != 0x14c || !bytes.Equal(v1[:ticketV1HeaderSize], expectedHeader) {
+		t.Fatalf("version 1 header is %x with 0x%x bytes, expected %x with 0x14c", v1[:ticketV1HeaderSize], len(v1), expectedHeader)
+	}
+
+	// The permanent section's records follow those of the content section.
+	permanentHeader := v1[ticketV1HeaderSize+ticketV1SectionHeaderSize:]
+	if offset := binary.BigEndian.Uint32(permanentHeader); offset != 0x144 {
+		t.Errorf("permanent section is at 0x%x, expected 0x144", offset)
+	}
+
+	loaded := WAD{}
+	if err = loaded.LoadTicket(source); err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(loaded.Ticket.V1, ticket.V1) {
+		t.Errorf("sections are %+v once loaded, expected %+v", loaded.Ticket.V1, ticket.V1)
+	}
+
+	written, err := loaded.GetTicket()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(written, source) {
+		t.Error("loaded ticket does not serialize to its source")
+	}
+}
+
+func TestCanAccessContent(t *testing.T) {
+	ticket := testV1Ticket(t)
+	records, err := ticket.V1.Sections[0].ContentIndexRecords()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(records) != 2 {
+		t.Fatalf("content section holds %d records, expected 2", len(records))
+	}
+
+	for _, test := range []struct {
+		record  int
+		index   uint32
+		allowed bool
+	}{
+		{0, 0, true},
+		{0, 1, false},
+		{0, 5, true},
+		{0, 1023, false},
+		// Indexes beyond a record's range are not permitted by it.
+		{0, 1027, false},
+		{1, 3, false},
+		{1, 1024, false},
+		{1, 1027, true},
+	} {
+		if allowed := records[test.record].CanAccessContent(test.index); allowed != test.allowed {
+			t.Errorf("record %d permits access to index %d: %t, expected %t", test.record, test.index, allowed, test.allowed)
+		}
+	}
+
+	// Only content sections hold content index records.
+	if _, err = ticket.V1.Sections[1].ContentIndexRecords(); err != ErrInvalidTicketSection {
+		t.Errorf("permanent section returned %v, expected ErrInvalidTicketSection", err)
+	}
+}
@@ -0,0 +1,158 @@
+package wadlib
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+)
+
+var (
+	ErrInvalidTitleID = errors.New("title ID should be in the form 00010001-48414545")
+)
+
+// TitleID represents a title's ID. Its upper 32 bits describe the title's category,
+// and its lower 32 bits typically describe the title itself, often as an ASCII game code.
+type TitleID uint64
+
+// TitleCategory describes the category of a title, as determined by the upper 32 bits of its ID.
+type TitleCategory uint32
+
+const (
+	// CategorySystem is used for IOS, boot2, BC, MIOS and the System Menu.
+	CategorySystem TitleCategory = 0x00000001
+	// CategoryDisc is used for titles run from disc.
+	CategoryDisc TitleCategory = 0x00010000
+	// CategoryChannel is used for downloaded channels, such as those from the Wii Shop Channel.
+	CategoryChannel TitleCategory = 0x00010001
+	// CategorySystemChannel is used for channels pre-installed on the console, such as the Mii Channel.
+	CategorySystemChannel TitleCategory = 0x00010002
+	// CategoryGameChannel is used for channels installed by disc-based games, such as Wii Fit Channel.
+	CategoryGameChannel TitleCategory = 0x00010004
+	// CategoryDLC is used for downloadable content for disc-based games.
+	CategoryDLC TitleCategory = 0x00010005
+	// CategoryHidden is used for titles not shown within the System Menu, such as the EULA.
+	CategoryHidden TitleCategory = 0x00010008
+)
+
+func (c TitleCategory) String() string {
+	switch c {
+	case CategorySystem:
+		return "system"
+	case CategoryDisc:
+		return "disc-based"
+	case CategoryChannel:
+		return "channel"
+	case CategorySystemChannel:
+		return "system channel"
+	case CategoryGameChannel:
+		return "game channel"
+	case CategoryDLC:
+		return "DLC"
+	case CategoryHidden:
+		return "hidden"
+	default:
+		return fmt.Sprintf("TitleCategory(%08x)", uint32(c))
+	}
+}
+
+// regionNames maps the final character of a game code to the region it describes.
+var regionNames = map[byte]string{
+	'A': "Region Free",
+	'C': "China",
+	'D': "Germany",
+	'E': "USA",
+	'F': "France",
+	'H': "Netherlands",
+	'I': "Italy",
+	'J': "Japan",
+	'K': "Korea",
+	'L': "Japanese import to Europe",
+	'M': "American import to Europe",
+	'N': "Japanese import to USA",
+	'P': "Europe",
+	'Q': "Korea with Japanese language",
+	'R': "Russia",
+	'S': "Spain",
+	'T': "Korea with English language",
+	'U': "Australia",
+	'V': "Scandinavia",
+	'W': "Taiwan",
+	'X': "Europe",
+	'Y': "Europe",
+	'Z': "Europe",
+}
+
+// NewTitleID returns a TitleID with the given upper and lower 32 bits.
+func NewTitleID(high uint32, low uint32) TitleID {
+	return TitleID(uint64(high)<<32 | uint64(low))
+}
+
+// ParseTitleID parses a title ID in its canonical form, such as "00010001-48414545".
+// The dash separating both halves may be omitted.
+func ParseTitleID(source string) (TitleID, error) {
+	// Only a dash separating both halves is permitted.
+	if len(source) == 17 && source[8] == '-' {
+		source = source[:8] + source[9:]
+	}
+
+	if len(source) != 16 {
+		return 0, ErrInvalidTitleID
+	}
+
+	value, err := strconv.ParseUint(source, 16, 64)
+	if err != nil {
+		return 0, ErrInvalidTitleID
+	}
+
+	return TitleID(value), nil
+}
+
+// High returns the upper 32 bits of this title ID.
+func (t TitleID) High() uint32 {
+	return uint32(t >> 32)
+}
+
+// Low returns the lower 32 bits of this title ID.
+func (t TitleID) Low() uint32 {
+	return uint32(t)
+}
+
+// Category returns the category of this title ID.
+func (t TitleID) Category() TitleCategory {
+	return TitleCategory(t.High())
+}
+
+// GameCode returns the lower 32 bits of this title ID as four ASCII characters, such as "HAEE".
+// If these bits are not printable characters, as with IOS, an empty string is returned.
+func (t TitleID) GameCode() string {
+	code := []byte{byte(t >> 24), byte(t >> 16), byte(t >> 8), byte(t)}
+	for _, character := range code {
+		if character < 0x20 || character > 0x7e {
+			return ""
+		}
+	}
+
+	return string(code)
+}
+
+// RegionLetter returns the final character of this title's game code, describing its region.
+// If this title has no game code, 0 is returned.
+func (t TitleID) RegionLetter() byte {
+	code := t.GameCode()
+	if code == "" {
+		return 0
+	}
+
+	return code[3]
+}
+
+// RegionName returns the name of the region inferred from this title's game code, such as "USA".
+// If the region cannot be determined, an empty string is returned.
+func (t TitleID) RegionName() string {
+	return regionNames[t.RegionLetter()]
+}
+
+// String returns this title ID in its canonical form, such as "00010001-48414545".
+func (t TitleID) String() string {
+	return fmt.Sprintf("%08x-%08x", t.High(), t.Low())
+}
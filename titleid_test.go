@@ -0,0 +1,33 @@
+package wadlib
+
+import "testing"
+
+func TestParseTitleID(t *testing.T) {
+	for _, test := range []struct {
+		source   string
+		expected TitleID
+		err      error
+	}{
+		{"00010001-48414545", 0x0001000148414545, nil},
+		{"0001000148414545", 0x0001000148414545, nil},
+		{"00010001-4841454A", 0x000100014841454a, nil},
+		{"0001-000148414545", 0, ErrInvalidTitleID},
+		{"-0001000148414545", 0, ErrInvalidTitleID},
+		{"0001000148414545-", 0, ErrInvalidTitleID},
+		{"00010001--48414545", 0, ErrInvalidTitleID},
+		{"00010001-4841454", 0, ErrInvalidTitleID},
+		{"00010001-4841454G", 0, ErrInvalidTitleID},
+		{"", 0, ErrInvalidTitleID},
+	} {
+		titleID, err := ParseTitleID(test.source)
+		if err != test.err || titleID != test.expected {
+			t.Errorf("%q parsed as %s with %v, expected %s with %v", test.source, titleID, err, test.expected, test.err)
+		}
+	}
+
+	// Title IDs should parse from their canonical form.
+	titleID := NewTitleID(0x00010002, 0x48414241)
+	if parsed, err := ParseTitleID(titleID.String()); err != nil || parsed != titleID {
+		t.Errorf("%s parsed as %s with %v", titleID, parsed, err)
+	}
+}
@@ -2,16 +2,25 @@ package wadlib
 
 import (
 	"bytes"
+	"crypto"
+	"crypto/sha1"
+	"crypto/sha256"
 	"encoding/binary"
+	"errors"
+	"hash"
 	"io/ioutil"
 )
 
-// BinaryTMD describes a byte-level format for a TMD.
+var (
+	ErrContentInfoMismatch = errors.New("content info records do not match the TMD's content records")
+)
+
+// tmdFakesignOffset is the offset of the padding within a TMD's signed contents altered while fakesigning.
+// With an RSA-2048 signature, this is 0x1e2 within the TMD.
+const tmdFakesignOffset = 0xa2
+
+// BinaryTMD describes a byte-level format for a TMD following its signature.
 type BinaryTMD struct {
-	SignatureType SignatureType
-	Signature     [256]byte
-	// Signature padding to nearest 64 bytes
-	_                 [60]byte
 	Issuer            [64]byte
 	FileVersion       uint8
 	CACRLVersion      uint8
@@ -19,7 +28,7 @@ type BinaryTMD struct {
 	IsvWii            bool
 	SystemVersionHigh uint32
 	SystemVersionLow  uint32
-	TitleID           uint64
+	TitleID           TitleID
 	TitleType         uint32
 	GroupID           uint16
 	Unknown           uint16
@@ -32,18 +41,49 @@ type BinaryTMD struct {
 	TitleVersion      uint16
 	NumberOfContents  uint16
 	BootIndex         uint16
-	// Further alignment
-	_ uint16
+	// Further alignment. This is altered while fakesigning.
+	Padding uint16
 }
 
 // TMD describes a human-usable TMD format.
 type TMD struct {
+	SignatureType SignatureType
+	// Signature is sized as appropriate for the signature type,
+	// and is followed by padding to the nearest 64 bytes.
+	Signature []byte
 	BinaryTMD
-	Contents []ContentRecord
+	// ContentInfoHash and ContentInfo are only present within version 1 TMDs.
+	// ContentInfoHash is the SHA-256 hash of all content info records.
+	ContentInfoHash [32]byte
+	ContentInfo     [64]ContentInfoRecord
+	Contents        []ContentRecord
 }
 
 // ContentRecord describes information about a given content.
 type ContentRecord struct {
+	ID    uint32
+	Index uint16
+	Type  ContentType
+	Size  uint64
+	// Hash is the SHA-1 hash of the decrypted content, as present within version 0 TMDs.
+	Hash [20]byte
+	// SHA256Hash is the SHA-256 hash of the decrypted content, as present within version 1 TMDs.
+	// It is zero for contents loaded from a version 0 TMD.
+	SHA256Hash [32]byte
+}
+
+// newHash returns a hash suitable to verify this content against, alongside the expected sum.
+// Contents with a SHA-256 hash, as within version 1 TMDs, are verified with such.
+func (c *ContentRecord) newHash() (hash.Hash, []byte) {
+	if c.SHA256Hash != [32]byte{} {
+		return sha256.New(), c.SHA256Hash[:]
+	}
+
+	return sha1.New(), c.Hash[:]
+}
+
+// binaryContentRecord describes the byte-level format of a content record within a version 0 TMD.
+type binaryContentRecord struct {
 	ID    uint32
 	Index uint16
 	Type  ContentType
@@ -51,51 +91,136 @@ type ContentRecord struct {
 	Hash  [20]byte
 }
 
+// binaryContentRecordV1 describes the byte-level format of a content record within a version 1 TMD.
+type binaryContentRecordV1 struct {
+	ID    uint32
+	Index uint16
+	Type  ContentType
+	Size  uint64
+	Hash  [32]byte
+}
+
+// ContentInfoRecord describes a group of content records within a version 1 TMD.
+// Up to 64 groups may be present, with unused groups zeroed.
+type ContentInfoRecord struct {
+	// IndexOffset is the position of the first content record within this group.
+	IndexOffset uint16
+	// CommandCount is the amount of content records within this group.
+	CommandCount uint16
+	// Hash is the SHA-256 hash of all content records within this group.
+	Hash [32]byte
+}
+
 // LoadTMD loads a given TMD from the passed contents into the WAD.
+// Version 1 TMDs, as determined by their file version, are additionally handled.
 func (w *WAD) LoadTMD(contents []byte) error {
 	loadingBuf := bytes.NewBuffer(contents)
 
+	// The signature's length differs per its type.
+	signatureType, signature, err := readSignature(loadingBuf)
+	if err != nil {
+		return newParseError("TMD signature", 0, err)
+	}
+
 	// We have to read in the statically positioned values first.
 	// The buffer will read in all it can,
 	// which should be all values up to the variable contents at its end.
-	// The primary length of the TMD struct is 484 bytes.
+	// With an RSA-2048 signature, the primary length of the TMD is 484 bytes.
 	var tmd BinaryTMD
-	err := binary.Read(loadingBuf, binary.BigEndian, &tmd)
+	offset := int64(len(contents) - loadingBuf.Len())
+	err = binary.Read(loadingBuf, binary.BigEndian, &tmd)
 	if err != nil {
-		return err
+		return newParseError("TMD", offset, err)
+	}
+
+	loaded := TMD{
+		SignatureType: signatureType,
+		Signature:     signature,
+		BinaryTMD:     tmd,
+	}
+
+	// Version 1 TMDs list groups of content records prior to the records themselves.
+	if tmd.FileVersion == 1 {
+		offset = int64(len(contents) - loadingBuf.Len())
+		err = binary.Read(loadingBuf, binary.BigEndian, &loaded.ContentInfoHash)
+		if err == nil {
+			err = binary.Read(loadingBuf, binary.BigEndian, &loaded.ContentInfo)
+		}
+		if err != nil {
+			return newParseError("TMD content info records", offset, err)
+		}
 	}
 
 	// Now, we create contents with the number of values as previously loaded.
-	contentIndex := make([]ContentRecord, tmd.NumberOfContents)
+	loaded.Contents = make([]ContentRecord, tmd.NumberOfContents)
 
 	// We can now read to the end of the TMD to our contents.
-	err = binary.Read(loadingBuf, binary.BigEndian, &contentIndex)
+	offset = int64(len(contents) - loadingBuf.Len())
+	if tmd.FileVersion == 1 {
+		records := make([]binaryContentRecordV1, tmd.NumberOfContents)
+		err = binary.Read(loadingBuf, binary.BigEndian, &records)
+		for index, record := range records {
+			loaded.Contents[index] = ContentRecord{
+				ID:         record.ID,
+				Index:      record.Index,
+				Type:       record.Type,
+				Size:       record.Size,
+				SHA256Hash: record.Hash,
+			}
+		}
+	} else {
+		records := make([]binaryContentRecord, tmd.NumberOfContents)
+		err = binary.Read(loadingBuf, binary.BigEndian, &records)
+		for index, record := range records {
+			loaded.Contents[index] = ContentRecord{
+				ID:    record.ID,
+				Index: record.Index,
+				Type:  record.Type,
+				Size:  record.Size,
+				Hash:  record.Hash,
+			}
+		}
+	}
 	if err != nil {
-		return err
+		return newParseError("TMD content records", offset, err)
 	}
 
-	w.TMD = TMD{
-		tmd,
-		contentIndex,
-	}
+	w.TMD = loaded
 	return nil
 }
 
 // GetTMD returns the bytes for the given TMD within the current WAD.
 func (w *WAD) GetTMD() ([]byte, error) {
-	// First, handle the fixed-length BinaryTMD.
+	return w.TMD.getBytes()
+}
+
+// getBytes returns the bytes of this TMD, including all content records.
+func (t *TMD) getBytes() ([]byte, error) {
 	var tmp bytes.Buffer
-	err := binary.Write(&tmp, binary.BigEndian, w.TMD.BinaryTMD)
+	err := writeSignature(&tmp, t.SignatureType, t.Signature)
 	if err != nil {
 		return nil, err
 	}
 
-	// Then, write all individual content records.
-	for _, content := range w.TMD.Contents {
-		err = binary.Write(&tmp, binary.BigEndian, content)
+	signed, err := t.getSignedBytes()
+	if err != nil {
+		return nil, err
+	}
+	tmp.Write(signed)
+
+	// Within version 1 TMDs, content info and content records are not signed.
+	// Instead, they are verified via the signed content info hash.
+	if t.FileVersion == 1 {
+		err = binary.Write(&tmp, binary.BigEndian, t.ContentInfo)
 		if err != nil {
 			return nil, err
 		}
+
+		records, err := t.getContentRecordBytes()
+		if err != nil {
+			return nil, err
+		}
+		tmp.Write(records)
 	}
 
 	// Read the buffer's contents.
@@ -106,3 +231,198 @@ func (w *WAD) GetTMD() ([]byte, error) {
 
 	return contents, nil
 }
+
+// getSignedBytes returns the portion of this TMD covered by its signature.
+// This begins at its issuer and extends through all content records.
+// For version 1 TMDs, this instead extends through the content info hash.
+// The content info records are written as-is; use UpdateContentInfo after altering content records.
+func (t *TMD) getSignedBytes() ([]byte, error) {
+	// First, handle the fixed-length BinaryTMD.
+	var tmp bytes.Buffer
+	err := binary.Write(&tmp, binary.BigEndian, t.BinaryTMD)
+	if err != nil {
+		return nil, err
+	}
+
+	if t.FileVersion == 1 {
+		tmp.Write(t.ContentInfoHash[:])
+		return tmp.Bytes(), nil
+	}
+
+	// Then, write all individual content records.
+	records, err := t.getContentRecordBytes()
+	if err != nil {
+		return nil, err
+	}
+	tmp.Write(records)
+
+	return tmp.Bytes(), nil
+}
+
+// getContentRecordBytes returns the bytes of all content records, in the format for this TMD's version.
+func (t *TMD) getContentRecordBytes() ([]byte, error) {
+	var tmp bytes.Buffer
+	for _, content := range t.Contents {
+		var record interface{}
+		if t.FileVersion == 1 {
+			record = binaryContentRecordV1{content.ID, content.Index, content.Type, content.Size, content.SHA256Hash}
+		} else {
+			record = binaryContentRecord{content.ID, content.Index, content.Type, content.Size, content.Hash}
+		}
+
+		err := binary.Write(&tmp, binary.BigEndian, record)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return tmp.Bytes(), nil
+}
+
+// getContentInfoHashes returns the hash for every group of content records as described
+// by the content info records, alongside the hash of the content info records themselves.
+func (t *TMD) getContentInfoHashes(info [64]ContentInfoRecord) ([64][32]byte, [32]byte, error) {
+	var groupHashes [64][32]byte
+	var infoHash [32]byte
+
+	records, err := t.getContentRecordBytes()
+	if err != nil {
+		return groupHashes, infoHash, err
+	}
+
+	for index, group := range info {
+		if group.CommandCount == 0 {
+			continue
+		}
+
+		start := int(group.IndexOffset) * binary.Size(binaryContentRecordV1{})
+		end := start + int(group.CommandCount)*binary.Size(binaryContentRecordV1{})
+		if end > len(records) {
+			return groupHashes, infoHash, ErrContentInfoMismatch
+		}
+
+		groupHashes[index] = sha256.Sum256(records[start:end])
+	}
+
+	var tmp bytes.Buffer
+	for index, group := range info {
+		group.Hash = groupHashes[index]
+		err = binary.Write(&tmp, binary.BigEndian, group)
+		if err != nil {
+			return groupHashes, infoHash, err
+		}
+	}
+
+	infoHash = sha256.Sum256(tmp.Bytes())
+	return groupHashes, infoHash, nil
+}
+
+// VerifyContentInfo ensures that the content info hash and content info records
+// within a version 1 TMD match the content records present.
+func (t *TMD) VerifyContentInfo() error {
+	if t.FileVersion != 1 {
+		return nil
+	}
+
+	groupHashes, infoHash, err := t.getContentInfoHashes(t.ContentInfo)
+	if err != nil {
+		return err
+	}
+
+	for index, group := range t.ContentInfo {
+		if group.Hash != groupHashes[index] {
+			return ErrContentInfoMismatch
+		}
+	}
+
+	if t.ContentInfoHash != infoHash {
+		return ErrContentInfoMismatch
+	}
+
+	return nil
+}
+
+// UpdateContentInfo updates the content info hash and content info records within a version 1 TMD
+// to match the content records present. Should the existing groups not cover all content records,
+// such as after contents are added or removed, a single group covering all content records is used.
+// WAD methods altering contents call this on their own. Otherwise, it should be called after
+// altering content records and prior to signing, as serializing writes content info as-is.
+func (t *TMD) UpdateContentInfo() error {
+	if t.FileVersion != 1 {
+		return nil
+	}
+
+	// Determine whether our groups still cover all records in sequence.
+	covered := 0
+	for _, group := range t.ContentInfo {
+		if group.CommandCount == 0 {
+			continue
+		}
+
+		if int(group.IndexOffset) != covered {
+			covered = -1
+			break
+		}
+		covered += int(group.CommandCount)
+	}
+
+	if covered != len(t.Contents) {
+		t.ContentInfo = [64]ContentInfoRecord{}
+		t.ContentInfo[0] = ContentInfoRecord{
+			IndexOffset:  0,
+			CommandCount: uint16(len(t.Contents)),
+		}
+	}
+
+	groupHashes, infoHash, err := t.getContentInfoHashes(t.ContentInfo)
+	if err != nil {
+		return err
+	}
+
+	for index := range t.ContentInfo {
+		t.ContentInfo[index].Hash = groupHashes[index]
+	}
+	t.ContentInfoHash = infoHash
+	return nil
+}
+
+// Fakesign zeroes the signature of this TMD, and alters its padding
+// until the SHA-1 hash of its signed contents begins with a null byte.
+// Consoles with a patched IOS will accept the resulting TMD.
+func (t *TMD) Fakesign() error {
+	signature, err := zeroSignature(t.SignatureType)
+	if err != nil {
+		return err
+	}
+	t.Signature = signature
+
+	signed, err := t.getSignedBytes()
+	if err != nil {
+		return err
+	}
+
+	value, err := bruteForceHash(signed, tmdFakesignOffset)
+	if err != nil {
+		return err
+	}
+
+	t.Padding = value
+	return nil
+}
+
+// Sign signs this TMD with the given RSA signer, listing the given issuer.
+// The issuer should be the full name of the signer's certificate, such as TMDIssuer.
+func (t *TMD) Sign(signer crypto.Signer, issuer string) error {
+	err := setIssuer(&t.Issuer, issuer)
+	if err != nil {
+		return err
+	}
+
+	signed, err := t.getSignedBytes()
+	if err != nil {
+		return err
+	}
+
+	t.SignatureType, t.Signature, err = signContents(signer, signed)
+	return err
+}
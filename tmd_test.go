@@ -0,0 +1,194 @@
+package wadlib
+
+import (
+	"bytes"
+	"encoding/binary"
+	"reflect"
+	"testing"
+)
+
+// testV1WAD returns a WAD from testWAD with a version 1 TMD, grouping its three contents as two and one.
+func testV1WAD(t *testing.T) *WAD {
+	t.Helper()
+
+	wad := testWAD(t, testWADContents()...)
+	wad.TMD.FileVersion = 1
+	wad.TMD.ContentInfo[0] = ContentInfoRecord{IndexOffset: 0, CommandCount: 2}
+	wad.TMD.ContentInfo[1] = ContentInfoRecord{IndexOffset: 2, CommandCount: 1}
+
+	if err := wad.TMD.UpdateContentInfo(); err != nil {
+		t.Fatal(err)
+	}
+
+	return wad
+}
+
+func TestTMDV1RoundTrip(t *testing.T) {
+	wad := testV1WAD(t)
+	source, err := wad.GetTMD()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The signature, body, content info hash, 64 content info records and 3 content records follow each other.
+	expectedSize := 0x140 + binary.Size(BinaryTMD{}) + 32 + 64*binary.Size(ContentInfoRecord{}) + 3*binary.Size(binaryContentRecordV1{})
+	if len(source) != expectedSize {
+		t.Fatalf("TMD is 0x%x bytes, expected 0x%x", len(source), expectedSize)
+	}
+
+	loaded := WAD{}
+	if err = loaded.LoadTMD(source); err != nil {
+		t.Fatal(err)
+	}
+
+	written, err := loaded.GetTMD()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(written, source) {
+		t.Error("loaded TMD does not serialize to its source")
+	}
+
+	if loaded.TMD.ContentInfo != wad.TMD.ContentInfo || loaded.TMD.ContentInfoHash != wad.TMD.ContentInfoHash {
+		t.Error("content info differs once loaded")
+	}
+
+	// Only SHA-256 hashes are present within version 1 content records.
+	for position, content := range loaded.TMD.Contents {
+		expected := wad.TMD.Contents[position]
+		expected.Hash = [20]byte{}
+		if !reflect.DeepEqual(content, expected) {
+			t.Errorf("content record at position %d differs once loaded", position)
+		}
+	}
+
+	if err = loaded.TMD.VerifyContentInfo(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestTMDV1Contents(t *testing.T) {
+	source, err := testV1WAD(t).GetWAD(WADTypeCommon)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	wad, err := LoadWAD(source)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Contents are verified against their SHA-256 hash as they are decrypted.
+	for position, content := range testWADContents() {
+		data, err := wad.GetContentAt(position)
+		if err != nil {
+			t.Fatalf("position %d: %v", position, err)
+		}
+
+		if !bytes.Equal(data, content) {
+			t.Errorf("content at position %d differs", position)
+		}
+	}
+
+	wad.TMD.Contents[0].SHA256Hash[0] ^= 0xff
+	if _, err = wad.GetContentAt(0); err == nil {
+		t.Error("content with a mismatched SHA-256 hash was decrypted")
+	}
+}
+
+func TestVerifyContentInfo(t *testing.T) {
+	for _, test := range []struct {
+		name   string
+		tamper func(*TMD)
+		valid  bool
+	}{
+		{"unaltered", func(*TMD) {}, true},
+		{"content record", func(tmd *TMD) { tmd.Contents[1].Size++ }, false},
+		{"content info record", func(tmd *TMD) { tmd.ContentInfo[1].Hash[0] ^= 0xff }, false},
+		{"content info hash", func(tmd *TMD) { tmd.ContentInfoHash[0] ^= 0xff }, false},
+		{"group beyond records", func(tmd *TMD) { tmd.ContentInfo[1].CommandCount = 2 }, false},
+	} {
+		wad := testV1WAD(t)
+		test.tamper(&wad.TMD)
+
+		// Serializing must retain the tampered content info as-is.
+		source, err := wad.GetTMD()
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+
+		loaded := WAD{}
+		if err = loaded.LoadTMD(source); err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+
+		for _, tmd := range []*TMD{&wad.TMD, &loaded.TMD} {
+			err = tmd.VerifyContentInfo()
+			if test.valid && err != nil {
+				t.Errorf("%s: returned %v, expected no error", test.name, err)
+			} else if !test.valid && err != ErrContentInfoMismatch {
+				t.Errorf("%s: returned %v, expected ErrContentInfoMismatch", test.name, err)
+			}
+		}
+	}
+}
+
+func TestUpdateContentInfo(t *testing.T) {
+	wad := testV1WAD(t)
+
+	// Existing groups are retained while they cover all records.
+	wad.TMD.Contents[2].Size++
+	if err := wad.TMD.UpdateContentInfo(); err != nil {
+		t.Fatal(err)
+	}
+	if wad.TMD.ContentInfo[0].CommandCount != 2 || wad.TMD.ContentInfo[1].CommandCount != 1 {
+		t.Error("groups covering all records were replaced")
+	}
+	if err := wad.TMD.VerifyContentInfo(); err != nil {
+		t.Error(err)
+	}
+	wad.TMD.Contents[2].Size--
+
+	// Otherwise, a single group covers all records.
+	if _, err := wad.AddContent([]byte("added content"), 0x10, TitleTypeNormal); err != nil {
+		t.Fatal(err)
+	}
+	testContentInfoGroup(t, wad, 4)
+
+	if err := wad.RemoveContent(1); err != nil {
+		t.Fatal(err)
+	}
+	testContentInfoGroup(t, wad, 3)
+
+	// Content info should remain valid through a write and reload.
+	source, err := wad.GetWAD(WADTypeCommon)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	reloaded, err := LoadWAD(source)
+	if err != nil {
+		t.Fatal(err)
+	}
+	testContentInfoGroup(t, reloaded, 3)
+}
+
+// testContentInfoGroup ensures the given WAD's TMD has a single, valid group covering the given amount of records.
+func testContentInfoGroup(t *testing.T, wad *WAD, records uint16) {
+	t.Helper()
+
+	expected := [64]ContentInfoRecord{}
+	expected[0] = ContentInfoRecord{
+		IndexOffset:  0,
+		CommandCount: records,
+		Hash:         wad.TMD.ContentInfo[0].Hash,
+	}
+	if wad.TMD.ContentInfo != expected {
+		t.Errorf("content info records are %v, expected a single group of %d", wad.TMD.ContentInfo[:2], records)
+	}
+
+	if err := wad.TMD.VerifyContentInfo(); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,200 @@
+package wadlib
+
+import (
+	"fmt"
+)
+
+// Region describes the region a title is intended for, as listed within its TMD.
+type Region uint16
+
+const (
+	RegionJapan  Region = 0
+	RegionUSA    Region = 1
+	RegionEurope Region = 2
+	// RegionFree is used by titles usable within all regions, such as IOS.
+	RegionFree  Region = 3
+	RegionKorea Region = 4
+)
+
+func (r Region) String() string {
+	switch r {
+	case RegionJapan:
+		return "Japan"
+	case RegionUSA:
+		return "USA"
+	case RegionEurope:
+		return "Europe"
+	case RegionFree:
+		return "Region Free"
+	case RegionKorea:
+		return "Korea"
+	default:
+		return fmt.Sprintf("Region(%d)", uint16(r))
+	}
+}
+
+// GetRegion returns the region of this title.
+func (t *TMD) GetRegion() Region {
+	return Region(t.Region)
+}
+
+// SetRegion sets the region of this title.
+func (t *TMD) SetRegion(region Region) {
+	t.Region = uint16(region)
+}
+
+// RatingBoard describes an age rating organization, by its position within the TMD's ratings.
+type RatingBoard int
+
+const (
+	// RatingCERO is used in Japan.
+	RatingCERO RatingBoard = 0
+	// RatingESRB is used in North America.
+	RatingESRB RatingBoard = 1
+	// RatingUSK is used in Germany.
+	RatingUSK RatingBoard = 3
+	// RatingPEGI is used across most of Europe.
+	RatingPEGI RatingBoard = 4
+	// RatingPEGIFinland is used in Finland.
+	RatingPEGIFinland RatingBoard = 5
+	// RatingPEGIPortugal is used in Portugal.
+	RatingPEGIPortugal RatingBoard = 6
+	// RatingBBFC is used in the United Kingdom.
+	RatingBBFC RatingBoard = 7
+	// RatingACB is used in Australia and New Zealand.
+	RatingACB RatingBoard = 8
+	// RatingGRB is used in Korea.
+	RatingGRB RatingBoard = 9
+)
+
+func (r RatingBoard) String() string {
+	switch r {
+	case RatingCERO:
+		return "CERO"
+	case RatingESRB:
+		return "ESRB"
+	case RatingUSK:
+		return "USK"
+	case RatingPEGI:
+		return "PEGI"
+	case RatingPEGIFinland:
+		return "PEGI (Finland)"
+	case RatingPEGIPortugal:
+		return "PEGI (Portugal)"
+	case RatingBBFC:
+		return "BBFC"
+	case RatingACB:
+		return "ACB"
+	case RatingGRB:
+		return "GRB"
+	default:
+		return fmt.Sprintf("RatingBoard(%d)", int(r))
+	}
+}
+
+const (
+	// ratingUnrated is set when a title has no rating with a board.
+	ratingUnrated = 0x80
+	// ratingAgeMask covers the minimum age within a rating.
+	ratingAgeMask = 0x1f
+)
+
+// GetRating returns the minimum age the given board has rated this title for.
+// If the title is not rated by the given board, false is returned.
+func (t *TMD) GetRating(board RatingBoard) (uint8, bool) {
+	if board < 0 || int(board) >= len(t.Ratings) {
+		return 0, false
+	}
+
+	rating := t.Ratings[board]
+	if rating&ratingUnrated != 0 {
+		return 0, false
+	}
+
+	return rating & ratingAgeMask, true
+}
+
+// SetRating sets the minimum age the given board has rated this title for.
+func (t *TMD) SetRating(board RatingBoard, age uint8) {
+	if board < 0 || int(board) >= len(t.Ratings) {
+		return
+	}
+
+	t.Ratings[board] = age & ratingAgeMask
+}
+
+// ClearRating marks this title as not being rated by the given board.
+func (t *TMD) ClearRating(board RatingBoard) {
+	if board < 0 || int(board) >= len(t.Ratings) {
+		return
+	}
+
+	t.Ratings[board] = ratingUnrated
+}
+
+// GetRequiredIOS returns the title ID of the IOS this title runs under, such as 00000001-0000003a for IOS58.
+// For IOS itself, this is the title ID of the boot2 version it requires.
+func (t *TMD) GetRequiredIOS() TitleID {
+	return NewTitleID(t.SystemVersionHigh, t.SystemVersionLow)
+}
+
+// SetRequiredIOS sets the title ID of the IOS this title runs under.
+func (t *TMD) SetRequiredIOS(ios TitleID) {
+	t.SystemVersionHigh = ios.High()
+	t.SystemVersionLow = ios.Low()
+}
+
+// TitleFlags describes the type of a title, as a set of flags.
+// These are named per Dolphin's observations.
+type TitleFlags uint32
+
+const (
+	// TitleFlagDefault is set by all official titles.
+	TitleFlagDefault TitleFlags = 0x1
+	// TitleFlagData is set by titles holding data, such as DLC.
+	TitleFlagData TitleFlags = 0x8
+	// TitleFlagWFS appears to be set by titles using WFS on the Wii U.
+	TitleFlagWFS TitleFlags = 0x20
+	// TitleFlagCT is set by CT titles.
+	TitleFlagCT TitleFlags = 0x40
+)
+
+// Has returns whether all of the given flags are set.
+func (f TitleFlags) Has(flags TitleFlags) bool {
+	return f&flags == flags
+}
+
+// GetTitleFlags returns the flags describing the type of this title.
+func (t *TMD) GetTitleFlags() TitleFlags {
+	return TitleFlags(t.TitleType)
+}
+
+// SetTitleFlags sets the flags describing the type of this title.
+func (t *TMD) SetTitleFlags(flags TitleFlags) {
+	t.TitleType = uint32(flags)
+}
+
+// AccessRights describes the hardware access this title is permitted.
+type AccessRights uint32
+
+const (
+	// AccessRightsAHBPROT disables AHB protection, permitting this title direct access to hardware.
+	AccessRightsAHBPROT AccessRights = 1 << 0
+	// AccessRightsDVDVideo permits this title to read DVD video discs.
+	AccessRightsDVDVideo AccessRights = 1 << 1
+)
+
+// Has returns whether all of the given rights are present.
+func (a AccessRights) Has(rights AccessRights) bool {
+	return a&rights == rights
+}
+
+// GetAccessRights returns the hardware access this title is permitted.
+func (t *TMD) GetAccessRights() AccessRights {
+	return AccessRights(t.AccessRightsFlags)
+}
+
+// SetAccessRights sets the hardware access this title is permitted.
+func (t *TMD) SetAccessRights(rights AccessRights) {
+	t.AccessRightsFlags = uint32(rights)
+}
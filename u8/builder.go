@@ -0,0 +1,310 @@
+package u8
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+const (
+	// dataAlignment is the alignment of the first file's data.
+	dataAlignment = 0x40
+	// fileAlignment is the alignment of each file's data.
+	fileAlignment = 0x20
+)
+
+// Builder creates a U8 archive from files and directories.
+// Within each directory, entries are written sorted by name.
+type Builder struct {
+	root *entry
+}
+
+// entry describes a file or directory to be written.
+type entry struct {
+	data     []byte
+	children map[string]*entry
+}
+
+func (e *entry) isDirectory() bool {
+	return e.children != nil
+}
+
+// NewBuilder returns a Builder with no files.
+func NewBuilder() *Builder {
+	return &Builder{
+		root: &entry{children: map[string]*entry{}},
+	}
+}
+
+// NewBuilderFromArchive returns a Builder holding all files and directories within the given archive.
+// This allows modifying an existing archive.
+func NewBuilderFromArchive(archive *Archive) (*Builder, error) {
+	b := NewBuilder()
+	for _, node := range archive.Nodes[1:] {
+		if node.Type == NodeDirectory {
+			err := b.AddDirectory(node.Path)
+			if err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		contents, err := archive.ReadFile(node.Path)
+		if err != nil {
+			return nil, err
+		}
+
+		err = b.AddFile(node.Path, contents)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return b, nil
+}
+
+// NewBuilderFromDirectory returns a Builder holding all files and directories beneath the given directory.
+func NewBuilderFromDirectory(directory string) (*Builder, error) {
+	b := NewBuilder()
+	err := filepath.Walk(directory, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		relative, err := filepath.Rel(directory, path)
+		if err != nil {
+			return err
+		}
+		relative = filepath.ToSlash(relative)
+
+		// The given directory is our root.
+		if relative == "." {
+			return nil
+		}
+
+		if info.IsDir() {
+			return b.AddDirectory(relative)
+		}
+
+		contents, err := ioutil.ReadFile(path)
+		if err != nil {
+			return err
+		}
+
+		return b.AddFile(relative, contents)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return b, nil
+}
+
+// lookup returns the directory with the given path, creating it and its parents if specified.
+func (b *Builder) lookup(path string, create bool) (*entry, error) {
+	current := b.root
+	path = cleanPath(path)
+	if path == "" {
+		return current, nil
+	}
+
+	for _, name := range strings.Split(path, "/") {
+		if !validName(name) {
+			return nil, ErrInvalidPath
+		}
+
+		child, ok := current.children[name]
+		switch {
+		case !ok && create:
+			child = &entry{children: map[string]*entry{}}
+			current.children[name] = child
+		case !ok:
+			return nil, ErrNotFound
+		case !child.isDirectory():
+			return nil, ErrInvalidPath
+		}
+
+		current = child
+	}
+
+	return current, nil
+}
+
+// splitPath returns the directory and name of the given path.
+func splitPath(path string) (string, string) {
+	path = cleanPath(path)
+	index := strings.LastIndex(path, "/")
+	if index == -1 {
+		return "", path
+	}
+
+	return path[:index], path[index+1:]
+}
+
+// AddDirectory adds a directory with the given path, alongside any parents.
+func (b *Builder) AddDirectory(path string) error {
+	_, err := b.lookup(path, true)
+	return err
+}
+
+// AddFile adds a file with the given path and contents, creating its parent directories.
+// If a file already exists with the given path, its contents are replaced.
+func (b *Builder) AddFile(path string, contents []byte) error {
+	directory, name := splitPath(path)
+	if !validName(name) {
+		return ErrInvalidPath
+	}
+
+	parent, err := b.lookup(directory, true)
+	if err != nil {
+		return err
+	}
+
+	if existing, ok := parent.children[name]; ok && existing.isDirectory() {
+		return ErrIsDirectory
+	}
+
+	parent.children[name] = &entry{data: contents}
+	return nil
+}
+
+// Remove removes the file or directory with the given path, alongside the contents of a directory.
+func (b *Builder) Remove(path string) error {
+	directory, name := splitPath(path)
+	parent, err := b.lookup(directory, false)
+	if err != nil {
+		return err
+	}
+
+	if _, ok := parent.children[name]; !ok {
+		return ErrNotFound
+	}
+
+	delete(parent.children, name)
+	return nil
+}
+
+// flatNode is a node to be written, alongside the data of a file.
+type flatNode struct {
+	binaryNode
+	data []byte
+}
+
+// flatten appends the given directory's contents to the given nodes and string table, depth first.
+func flatten(directory *entry, parentIndex int, nodes []flatNode, stringTable *bytes.Buffer) []flatNode {
+	names := make([]string, 0, len(directory.children))
+	for name := range directory.children {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		child := directory.children[name]
+		index := len(nodes)
+
+		node := flatNode{
+			binaryNode: binaryNode{
+				TypeAndName: uint32(stringTable.Len()),
+			},
+			data: child.data,
+		}
+		stringTable.WriteString(name)
+		stringTable.WriteByte(0)
+
+		if !child.isDirectory() {
+			nodes = append(nodes, node)
+			continue
+		}
+
+		node.TypeAndName |= uint32(NodeDirectory) << 24
+		node.DataOffset = uint32(parentIndex)
+		nodes = append(nodes, node)
+		nodes = flatten(child, index, nodes, stringTable)
+
+		// Our size is determined only once our contents are written.
+		nodes[index].Size = uint32(len(nodes))
+	}
+
+	return nodes
+}
+
+// align returns the given offset aligned to the given boundary.
+func align(offset uint32, alignment uint32) uint32 {
+	return (offset + alignment - 1) / alignment * alignment
+}
+
+// WriteTo writes the archive to the given writer.
+func (b *Builder) WriteTo(out io.Writer) (int64, error) {
+	// The root node has an empty name, at the start of the string table.
+	var stringTable bytes.Buffer
+	stringTable.WriteByte(0)
+
+	nodes := []flatNode{{
+		binaryNode: binaryNode{
+			TypeAndName: uint32(NodeDirectory) << 24,
+		},
+	}}
+	nodes = flatten(b.root, 0, nodes, &stringTable)
+	nodes[0].Size = uint32(len(nodes))
+
+	header := binaryHeader{
+		Magic:          Magic,
+		RootNodeOffset: headerSize,
+		HeaderSize:     uint32(len(nodes)*nodeSize + stringTable.Len()),
+	}
+	header.DataOffset = align(header.RootNodeOffset+header.HeaderSize, dataAlignment)
+
+	// Each file's data is aligned, following the last.
+	offset := header.DataOffset
+	for index := range nodes {
+		if NodeType(nodes[index].TypeAndName>>24) != NodeFile {
+			continue
+		}
+
+		offset = align(offset, fileAlignment)
+		nodes[index].DataOffset = offset
+		nodes[index].Size = uint32(len(nodes[index].data))
+		offset += nodes[index].Size
+	}
+
+	var tmp bytes.Buffer
+	err := binary.Write(&tmp, binary.BigEndian, header)
+	if err != nil {
+		return 0, err
+	}
+
+	for _, node := range nodes {
+		err = binary.Write(&tmp, binary.BigEndian, node.binaryNode)
+		if err != nil {
+			return 0, err
+		}
+	}
+	tmp.Write(stringTable.Bytes())
+
+	for _, node := range nodes {
+		if NodeType(node.TypeAndName>>24) != NodeFile {
+			continue
+		}
+
+		tmp.Write(make([]byte, int(node.DataOffset)-tmp.Len()))
+		tmp.Write(node.data)
+	}
+
+	return tmp.WriteTo(out)
+}
+
+// Bytes returns the archive as bytes.
+func (b *Builder) Bytes() ([]byte, error) {
+	var tmp bytes.Buffer
+	_, err := b.WriteTo(&tmp)
+	if err != nil {
+		return nil, err
+	}
+
+	return tmp.Bytes(), nil
+}
@@ -0,0 +1,330 @@
+// Package u8 reads and writes U8 archives, as commonly held within the contents of a WAD.
+package u8
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+var (
+	ErrInvalidMagic = errors.New("data is not a U8 archive")
+	ErrInvalidNode  = errors.New("U8 archive contains a malformed node")
+	ErrNotFound     = errors.New("path does not exist within U8 archive")
+	ErrIsDirectory  = errors.New("path is a directory within U8 archive")
+	ErrInvalidPath  = errors.New("path is not valid within a U8 archive")
+)
+
+// Magic is the value U8 archives begin with.
+const Magic = 0x55aa382d
+
+const (
+	// headerSize is the size of the header preceding the node table.
+	headerSize = 0x20
+	// nodeSize is the size of a single node within the node table.
+	nodeSize = 0x0c
+)
+
+// NodeType describes whether a node is a file or a directory.
+type NodeType uint8
+
+const (
+	NodeFile      NodeType = 0
+	NodeDirectory NodeType = 1
+)
+
+// binaryHeader describes the byte-level format of a U8 archive's header.
+type binaryHeader struct {
+	Magic uint32
+	// RootNodeOffset is the offset of the node table, beginning with the root node.
+	RootNodeOffset uint32
+	// HeaderSize is the size of both the node and string tables.
+	HeaderSize uint32
+	// DataOffset is the offset of the first file's data.
+	DataOffset uint32
+	Reserved   [16]byte
+}
+
+// binaryNode describes the byte-level format of a node within the node table.
+type binaryNode struct {
+	// TypeAndName holds the node's type within its upper 8 bits,
+	// and the offset of its name within the string table within the lower 24 bits.
+	TypeAndName uint32
+	// DataOffset is the offset of a file's data, or the index of a directory's parent.
+	DataOffset uint32
+	// Size is the size of a file's data, or the index of the first node
+	// following a directory that is not within it.
+	Size uint32
+}
+
+// Node describes a file or directory within a U8 archive.
+type Node struct {
+	Type NodeType
+	Name string
+	// Path is the path of this node from the root, separated by slashes, such as "arc/anim/banner.brlan".
+	// The root node's path is empty.
+	Path string
+	// Parent is the index of the directory holding this node.
+	Parent int
+	// Offset and Size describe a file's data within the archive.
+	Offset uint32
+	Size   uint32
+	// End is the index of the first node following a directory that is not within it.
+	End int
+}
+
+// Archive describes a U8 archive. Its nodes are listed in the order present within the archive,
+// with directories immediately followed by their contents. The first node is the root directory.
+type Archive struct {
+	Nodes  []Node
+	source io.ReaderAt
+}
+
+// Load parses the U8 archive held within the given data.
+func Load(data []byte) (*Archive, error) {
+	return Open(bytes.NewReader(data), int64(len(data)))
+}
+
+// Open parses the U8 archive available from the given reader, of the given size.
+// Only the node and string tables are read immediately. File data is read as requested,
+// and as such the reader must remain available.
+func Open(source io.ReaderAt, size int64) (*Archive, error) {
+	var header binaryHeader
+	err := binary.Read(io.NewSectionReader(source, 0, size), binary.BigEndian, &header)
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		return nil, ErrInvalidMagic
+	} else if err != nil {
+		return nil, err
+	}
+
+	if header.Magic != Magic {
+		return nil, ErrInvalidMagic
+	}
+
+	// Both the node and string tables must lie within the archive, and must hold at least the root node.
+	tablesEnd := int64(header.RootNodeOffset) + int64(header.HeaderSize)
+	if header.HeaderSize < nodeSize || tablesEnd > size {
+		return nil, ErrInvalidNode
+	}
+
+	tables := make([]byte, header.HeaderSize)
+	_, err = source.ReadAt(tables, int64(header.RootNodeOffset))
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+
+	// The root node's size is the amount of nodes in total.
+	root := readNode(tables, 0)
+	nodeCount := int(root.Size)
+	if NodeType(root.TypeAndName>>24) != NodeDirectory || nodeCount < 1 || nodeCount*nodeSize > len(tables) {
+		return nil, ErrInvalidNode
+	}
+	stringTable := tables[nodeCount*nodeSize:]
+
+	archive := Archive{
+		Nodes: []Node{{
+			Type: NodeDirectory,
+			End:  nodeCount,
+		}},
+		source: source,
+	}
+
+	// We track the directories the current node is nested within.
+	directories := []int{0}
+	for index := 1; index < nodeCount; index++ {
+		for index >= archive.Nodes[directories[len(directories)-1]].End {
+			directories = directories[:len(directories)-1]
+		}
+		parent := directories[len(directories)-1]
+
+		current := readNode(tables, index)
+		name, err := readName(stringTable, current.TypeAndName&0xffffff)
+		if err != nil {
+			return nil, err
+		}
+
+		node := Node{
+			Type:   NodeType(current.TypeAndName >> 24),
+			Name:   name,
+			Path:   joinPath(archive.Nodes[parent].Path, name),
+			Parent: parent,
+		}
+
+		switch node.Type {
+		case NodeFile:
+			if int64(current.DataOffset)+int64(current.Size) > size {
+				return nil, ErrInvalidNode
+			}
+
+			node.Offset = current.DataOffset
+			node.Size = current.Size
+		case NodeDirectory:
+			// A directory must end within its parent.
+			node.End = int(current.Size)
+			if node.End <= index || node.End > archive.Nodes[parent].End {
+				return nil, ErrInvalidNode
+			}
+
+			directories = append(directories, index)
+		default:
+			return nil, ErrInvalidNode
+		}
+
+		archive.Nodes = append(archive.Nodes, node)
+	}
+
+	return &archive, nil
+}
+
+// readNode reads the node with the given index from the node table.
+func readNode(tables []byte, index int) binaryNode {
+	offset := index * nodeSize
+	return binaryNode{
+		TypeAndName: binary.BigEndian.Uint32(tables[offset:]),
+		DataOffset:  binary.BigEndian.Uint32(tables[offset+4:]),
+		Size:        binary.BigEndian.Uint32(tables[offset+8:]),
+	}
+}
+
+// readName reads the null-terminated name at the given offset within the string table.
+// Names must not be able to escape their directory once extracted.
+func readName(stringTable []byte, offset uint32) (string, error) {
+	if int(offset) >= len(stringTable) {
+		return "", ErrInvalidNode
+	}
+
+	name := stringTable[offset:]
+	end := bytes.IndexByte(name, 0)
+	if end == -1 {
+		return "", ErrInvalidNode
+	}
+
+	if !validName(string(name[:end])) {
+		return "", ErrInvalidNode
+	}
+	return string(name[:end]), nil
+}
+
+// validName determines whether the given name is usable for a file or directory.
+// Note that many archives hold a single directory named ".", containing all files.
+func validName(name string) bool {
+	return name != "" && name != ".." && !strings.ContainsAny(name, "/\\\x00")
+}
+
+// joinPath joins the given directory path and name.
+func joinPath(directory string, name string) string {
+	if directory == "" {
+		return name
+	}
+
+	return directory + "/" + name
+}
+
+// cleanPath normalizes the given path, permitting leading and trailing slashes.
+func cleanPath(path string) string {
+	return strings.Trim(path, "/")
+}
+
+// Find returns the index of the node with the given path.
+func (a *Archive) Find(path string) (int, error) {
+	path = cleanPath(path)
+	for index, node := range a.Nodes {
+		if node.Path == path {
+			return index, nil
+		}
+	}
+
+	return 0, ErrNotFound
+}
+
+// Files returns all files within this archive.
+func (a *Archive) Files() []Node {
+	var files []Node
+	for _, node := range a.Nodes {
+		if node.Type == NodeFile {
+			files = append(files, node)
+		}
+	}
+
+	return files
+}
+
+// OpenFile returns a reader for the file with the given path.
+func (a *Archive) OpenFile(path string) (*io.SectionReader, error) {
+	index, err := a.Find(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return a.openNode(a.Nodes[index])
+}
+
+func (a *Archive) openNode(node Node) (*io.SectionReader, error) {
+	if node.Type != NodeFile {
+		return nil, ErrIsDirectory
+	}
+
+	return io.NewSectionReader(a.source, int64(node.Offset), int64(node.Size)), nil
+}
+
+// ReadFile returns the contents of the file with the given path.
+func (a *Archive) ReadFile(path string) ([]byte, error) {
+	reader, err := a.OpenFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	contents := make([]byte, reader.Size())
+	_, err = io.ReadFull(reader, contents)
+	if err != nil {
+		return nil, err
+	}
+
+	return contents, nil
+}
+
+// Extract writes all files and directories within this archive beneath the given directory.
+func (a *Archive) Extract(directory string) error {
+	for _, node := range a.Nodes {
+		path := filepath.Join(directory, filepath.FromSlash(node.Path))
+
+		if node.Type == NodeDirectory {
+			err := os.MkdirAll(path, 0755)
+			if err != nil {
+				return err
+			}
+			continue
+		}
+
+		reader, err := a.openNode(node)
+		if err != nil {
+			return err
+		}
+
+		err = extractFile(path, reader)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func extractFile(path string, reader io.Reader) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+
+	_, err = io.Copy(file, reader)
+	if err != nil {
+		file.Close()
+		return err
+	}
+
+	return file.Close()
+}
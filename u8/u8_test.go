@@ -0,0 +1,145 @@
+package u8
+
+import (
+	"bytes"
+	"testing"
+)
+
+var testFiles = map[string][]byte{
+	"meta/icon.bin":      []byte("icon"),
+	"meta/banner.bin":    bytes.Repeat([]byte("banner"), 100),
+	"meta/sound.bin":     {},
+	"arc/anim/a.brlan":   []byte("animation"),
+	"arc/timg/image.tpl": bytes.Repeat([]byte{0xff}, 0x45),
+}
+
+// testArchive returns an archive holding testFiles, alongside an empty directory.
+func testArchive(t *testing.T) []byte {
+	b := NewBuilder()
+	for path, contents := range testFiles {
+		err := b.AddFile(path, contents)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	err := b.AddDirectory("arc/empty")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	archive, err := b.Bytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return archive
+}
+
+func TestRoundTrip(t *testing.T) {
+	archive, err := Load(testArchive(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for path, expected := range testFiles {
+		contents, err := archive.ReadFile(path)
+		if err != nil {
+			t.Errorf("%s: %v", path, err)
+			continue
+		}
+
+		if !bytes.Equal(contents, expected) {
+			t.Errorf("%s: contents differ after round trip", path)
+		}
+	}
+
+	if len(archive.Files()) != len(testFiles) {
+		t.Errorf("archive holds %d files, expected %d", len(archive.Files()), len(testFiles))
+	}
+
+	if _, err = archive.Find("arc/empty"); err != nil {
+		t.Errorf("empty directory: %v", err)
+	}
+
+	if _, err = archive.ReadFile("meta"); err != ErrIsDirectory {
+		t.Errorf("reading a directory returned %v, expected ErrIsDirectory", err)
+	}
+
+	if _, err = archive.ReadFile("meta/missing.bin"); err != ErrNotFound {
+		t.Errorf("reading a missing file returned %v, expected ErrNotFound", err)
+	}
+}
+
+func TestExtractRebuild(t *testing.T) {
+	original := testArchive(t)
+	archive, err := Load(original)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+	err = archive.Extract(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := NewBuilderFromDirectory(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rebuilt, err := b.Bytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(rebuilt, original) {
+		t.Error("archive rebuilt from its extracted contents differs")
+	}
+}
+
+// replaceName returns the given archive with the first occurrence of a name replaced.
+// Both names must be of the same length.
+func replaceName(t *testing.T, archive []byte, name string, replacement string) []byte {
+	needle := append([]byte(name), 0)
+	index := bytes.Index(archive, needle)
+	if index == -1 || len(name) != len(replacement) {
+		t.Fatalf("unable to replace %q", name)
+	}
+
+	modified := append([]byte{}, archive...)
+	copy(modified[index:], replacement)
+	return modified
+}
+
+func TestInvalidNames(t *testing.T) {
+	archive := testArchive(t)
+	for _, test := range []struct {
+		name        string
+		replacement string
+	}{
+		{"arc", "../"},
+		{"meta", "a/.."},
+		{"anim", "an/m"},
+		{"a.brlan", "..\x00rlan"},
+	} {
+		_, err := Load(replaceName(t, archive, test.name, test.replacement))
+		if err != ErrInvalidNode {
+			t.Errorf("name %q returned %v, expected ErrInvalidNode", test.replacement, err)
+		}
+	}
+
+	for _, path := range []string{"../escape", "a/../b", "a/b\x00"} {
+		if err := NewBuilder().AddFile(path, nil); err != ErrInvalidPath {
+			t.Errorf("adding %q returned %v, expected ErrInvalidPath", path, err)
+		}
+	}
+}
+
+func TestInvalidMagic(t *testing.T) {
+	archive := testArchive(t)
+	archive[0] ^= 0xff
+	if _, err := Load(archive); err != ErrInvalidMagic {
+		t.Errorf("returned %v, expected ErrInvalidMagic", err)
+	}
+}
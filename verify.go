@@ -0,0 +1,219 @@
+package wadlib
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"fmt"
+	"io"
+)
+
+// ContentStatus describes the outcome of verifying a single content.
+type ContentStatus int
+
+const (
+	// ContentValid is reported when a content's decrypted data matches the hash within its record.
+	ContentValid ContentStatus = iota
+	// ContentHashMismatch is reported when a content's decrypted data does not match its hash.
+	ContentHashMismatch
+	// ContentSizeMismatch is reported when a content's encrypted data cannot cover the size within its record.
+	ContentSizeMismatch
+	// ContentMissing is reported when a content listed within the TMD has no data.
+	ContentMissing
+)
+
+func (s ContentStatus) String() string {
+	switch s {
+	case ContentValid:
+		return "ok"
+	case ContentHashMismatch:
+		return "hash mismatch"
+	case ContentSizeMismatch:
+		return "size mismatch"
+	case ContentMissing:
+		return "missing data"
+	default:
+		return fmt.Sprintf("ContentStatus(%d)", int(s))
+	}
+}
+
+// ContentResult describes the outcome of verifying a single content.
+type ContentResult struct {
+	ID     uint32
+	Index  uint16
+	Status ContentStatus
+	// Err holds the error encountered while reading this content's data, if any.
+	// Its status is ContentMissing in such a case.
+	Err error
+}
+
+// VerifyReport describes the outcome of verifying a WAD's contents,
+// alongside the consistency of its ticket and TMD.
+type VerifyReport struct {
+	// Contents holds results in the order contents are listed within the TMD.
+	Contents []ContentResult
+	// TitleIDMismatch is set when the ticket and TMD list differing title IDs.
+	TitleIDMismatch bool
+	// TitleVersionMismatch is set when the ticket and TMD list differing title versions.
+	TitleVersionMismatch bool
+	// ContentCountMismatch is set when the TMD's NumberOfContents differs from its amount of content records.
+	ContentCountMismatch bool
+	// ContentInfoMismatch is set when a version 1 TMD's content info records do not match its content records.
+	ContentInfoMismatch bool
+}
+
+// Valid returns whether all contents are valid, and the ticket and TMD are consistent.
+func (r *VerifyReport) Valid() bool {
+	for _, content := range r.Contents {
+		if content.Status != ContentValid {
+			return false
+		}
+	}
+
+	return !r.TitleIDMismatch && !r.TitleVersionMismatch && !r.ContentCountMismatch && !r.ContentInfoMismatch
+}
+
+// Verify verifies the hash of every content within this WAD, and the consistency of its ticket and TMD.
+// Contents are decrypted and hashed in chunks, and are never held entirely in memory.
+// An error is only returned if verification cannot take place, such as if the title key is unavailable.
+func (w *WAD) Verify() (*VerifyReport, error) {
+	titleKey, err := w.Ticket.GetTitleKey()
+	if err != nil {
+		return nil, err
+	}
+
+	report := VerifyReport{
+		TitleIDMismatch:      w.Ticket.TitleID != w.TMD.TitleID,
+		TitleVersionMismatch: w.Ticket.TitleVersion != w.TMD.TitleVersion,
+		ContentCountMismatch: int(w.TMD.NumberOfContents) != len(w.TMD.Contents),
+	}
+
+	err = w.TMD.VerifyContentInfo()
+	if err == ErrContentInfoMismatch {
+		report.ContentInfoMismatch = true
+	} else if err != nil {
+		return nil, err
+	}
+
+	for position, record := range w.TMD.Contents {
+		result := ContentResult{
+			ID:     record.ID,
+			Index:  record.Index,
+			Status: ContentMissing,
+		}
+
+		// Data is ordered by position within the TMD.
+		if position < len(w.Data) {
+			result.Status, result.Err = w.Data[position].verify(record, titleKey)
+		}
+
+		report.Contents = append(report.Contents, result)
+	}
+
+	return &report, nil
+}
+
+// Verify verifies the hash of this content's data against its record, decrypting with the given title key.
+// As with WAD.Verify, an error is only returned if this content's data cannot be read.
+func (d *WADFile) Verify(titleKey [16]byte) (ContentStatus, error) {
+	return d.verify(*d.Record, titleKey)
+}
+
+// verify decrypts and hashes this content's data in chunks, comparing against the given record.
+func (d *WADFile) verify(record ContentRecord, titleKey [16]byte) (ContentStatus, error) {
+	// Data to be encrypted on the fly is already decrypted.
+	if d.plaintext != nil {
+		if d.plaintext.Size() != int64(record.Size) {
+			return ContentSizeMismatch, nil
+		}
+
+		return verifyHash(record, io.NewSectionReader(d.plaintext, 0, d.plaintext.Size()))
+	}
+
+	var source io.Reader
+	var size int64
+	switch {
+	case d.source != nil:
+		source = io.NewSectionReader(d.source, 0, d.source.Size())
+		size = d.source.Size()
+	case len(d.RawData) == 0 && record.Size != 0:
+		return ContentMissing, nil
+	default:
+		source = bytes.NewReader(d.RawData)
+		size = int64(len(d.RawData))
+	}
+
+	// Encrypted data must be aligned to the AES block size, and cover the size of our content.
+	if size%aes.BlockSize != 0 || size < int64(record.Size) {
+		return ContentSizeMismatch, nil
+	}
+
+	// It should not be possible for our key not to be 16 bytes.
+	block, err := aes.NewCipher(titleKey[:])
+	if err != nil {
+		panic(err)
+	}
+
+	blockMode := cipher.NewCBCDecrypter(block, getContentIV(record.Index))
+	decrypted := &decryptingReader{
+		source:    io.LimitReader(source, int64(getEncryptedSize(record))),
+		blockMode: blockMode,
+	}
+
+	// Trim off the excess padding once decrypted.
+	return verifyHash(record, io.LimitReader(decrypted, int64(record.Size)))
+}
+
+// verifyHash hashes the given decrypted data, comparing against the given record.
+func verifyHash(record ContentRecord, source io.Reader) (ContentStatus, error) {
+	hash, expected := record.newHash()
+	written, err := io.CopyBuffer(hash, source, make([]byte, encryptionChunkSize))
+	if err != nil {
+		return ContentMissing, err
+	}
+
+	if uint64(written) != record.Size {
+		return ContentSizeMismatch, nil
+	}
+
+	if !bytes.Equal(hash.Sum(nil), expected) {
+		return ContentHashMismatch, nil
+	}
+	return ContentValid, nil
+}
+
+// decryptingReader decrypts data from its source as it is read.
+// Its source must provide a multiple of the AES block size.
+type decryptingReader struct {
+	source    io.Reader
+	blockMode cipher.BlockMode
+	// pending holds decrypted data not yet read.
+	pending []byte
+	buffer  []byte
+}
+
+func (r *decryptingReader) Read(p []byte) (int, error) {
+	if len(r.pending) == 0 {
+		if r.buffer == nil {
+			r.buffer = make([]byte, encryptionChunkSize)
+		}
+
+		read, err := io.ReadFull(r.source, r.buffer)
+		if err == io.EOF {
+			return 0, io.EOF
+		} else if err != nil && err != io.ErrUnexpectedEOF {
+			return 0, err
+		}
+
+		if read%aes.BlockSize != 0 {
+			return 0, io.ErrUnexpectedEOF
+		}
+
+		r.blockMode.CryptBlocks(r.buffer[:read], r.buffer[:read])
+		r.pending = r.buffer[:read]
+	}
+
+	copied := copy(p, r.pending)
+	r.pending = r.pending[copied:]
+	return copied, nil
+}
@@ -0,0 +1,226 @@
+package wadlib
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestContentStatus(t *testing.T) {
+	// A content larger than encryptionChunkSize is decrypted and hashed across several chunks.
+	contents := append(testWADContents(), bytes.Repeat([]byte{0xdd}, encryptionChunkSize+0x10))
+	source := testWADBytes(t, contents...)
+	_, _, dataOffset := testSectionOffsets(t, source)
+
+	for _, test := range []struct {
+		name     string
+		load     func() (*WAD, error)
+		tamper   func(*WAD)
+		expected ContentStatus
+	}{
+		{
+			name:     "valid",
+			tamper:   func(*WAD) {},
+			expected: ContentValid,
+		},
+		{
+			name: "corrupted data",
+			tamper: func(wad *WAD) {
+				wad.Data[3].RawData[encryptionChunkSize+1] ^= 0xff
+			},
+			expected: ContentHashMismatch,
+		},
+		{
+			name: "corrupted lazily loaded data",
+			load: func() (*WAD, error) {
+				corrupted := append([]byte{}, source...)
+				corrupted[dataOffset] ^= 0xff
+				return LoadWADFromReader(bytes.NewReader(corrupted), int64(len(corrupted)))
+			},
+			tamper:   func(*WAD) {},
+			expected: ContentHashMismatch,
+		},
+		{
+			name: "mismatched hash",
+			tamper: func(wad *WAD) {
+				wad.Data[3].Record.Hash[0] ^= 0xff
+			},
+			expected: ContentHashMismatch,
+		},
+		{
+			name: "size beyond data",
+			tamper: func(wad *WAD) {
+				wad.Data[3].Record.Size += 16
+			},
+			expected: ContentSizeMismatch,
+		},
+		{
+			name: "unaligned data",
+			tamper: func(wad *WAD) {
+				wad.Data[3].RawData = wad.Data[3].RawData[:len(wad.Data[3].RawData)-1]
+			},
+			expected: ContentSizeMismatch,
+		},
+		{
+			name: "valid data to encrypt",
+			tamper: func(wad *WAD) {
+				if err := wad.UpdateContentFromReader(3, bytes.NewReader(contents[3]), int64(len(contents[3]))); err != nil {
+					t.Fatal(err)
+				}
+			},
+			expected: ContentValid,
+		},
+		{
+			name: "size differing from data to encrypt",
+			tamper: func(wad *WAD) {
+				if err := wad.UpdateContentFromReader(3, bytes.NewReader(contents[3]), int64(len(contents[3]))); err != nil {
+					t.Fatal(err)
+				}
+				wad.Data[3].Record.Size++
+			},
+			expected: ContentSizeMismatch,
+		},
+		{
+			name: "missing data",
+			tamper: func(wad *WAD) {
+				wad.Data[3].RawData = nil
+			},
+			expected: ContentMissing,
+		},
+	} {
+		load := test.load
+		if load == nil {
+			load = func() (*WAD, error) {
+				return LoadWAD(append([]byte{}, source...))
+			}
+		}
+
+		wad, err := load()
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		test.tamper(wad)
+
+		report, err := wad.Verify()
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+
+		// Only the final content is tampered with, other than when loaded lazily.
+		tampered := len(report.Contents) - 1
+		if test.load != nil {
+			tampered = 0
+		}
+
+		for position, result := range report.Contents {
+			expected := ContentValid
+			if position == tampered {
+				expected = test.expected
+			}
+
+			if result.Status != expected {
+				t.Errorf("%s: content at position %d is %s, expected %s", test.name, position, result.Status, expected)
+			}
+		}
+
+		if report.Valid() != (test.expected == ContentValid) {
+			t.Errorf("%s: report is valid: %t", test.name, report.Valid())
+		}
+
+		// Verifying a single content should agree.
+		titleKey, err := wad.Ticket.GetTitleKey()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		status, err := wad.Data[tampered].Verify(titleKey)
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		if status != test.expected {
+			t.Errorf("%s: content is %s when verified alone, expected %s", test.name, status, test.expected)
+		}
+	}
+}
+
+func TestVerifyReport(t *testing.T) {
+	for _, test := range []struct {
+		name   string
+		wad    func() *WAD
+		tamper func(*WAD)
+		// mismatch returns the flag expected to be set within the given report.
+		mismatch func(*VerifyReport) *bool
+	}{
+		{
+			// The ticket's title ID is the IV for its title key, so the TMD's is altered.
+			name: "title ID",
+			tamper: func(wad *WAD) {
+				wad.TMD.TitleID = NewTitleID(0x00010001, 0x48424242)
+			},
+			mismatch: func(report *VerifyReport) *bool { return &report.TitleIDMismatch },
+		},
+		{
+			name: "title version",
+			tamper: func(wad *WAD) {
+				wad.TMD.TitleVersion++
+			},
+			mismatch: func(report *VerifyReport) *bool { return &report.TitleVersionMismatch },
+		},
+		{
+			name: "content count",
+			tamper: func(wad *WAD) {
+				wad.TMD.NumberOfContents++
+			},
+			mismatch: func(report *VerifyReport) *bool { return &report.ContentCountMismatch },
+		},
+		{
+			name: "content info",
+			wad: func() *WAD {
+				return testV1WAD(t)
+			},
+			tamper: func(wad *WAD) {
+				wad.TMD.ContentInfoHash[0] ^= 0xff
+			},
+			mismatch: func(report *VerifyReport) *bool { return &report.ContentInfoMismatch },
+		},
+	} {
+		wad := testWAD(t, testWADContents()...)
+		if test.wad != nil {
+			wad = test.wad()
+		}
+
+		// Each WAD should be valid prior to being tampered with.
+		report, err := wad.Verify()
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		if !report.Valid() {
+			t.Fatalf("%s: unaltered WAD is not valid: %+v", test.name, report)
+		}
+
+		test.tamper(wad)
+		report, err = wad.Verify()
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+
+		// Only the expected flag should be set.
+		expected := VerifyReport{Contents: report.Contents}
+		*test.mismatch(&expected) = true
+		if report.TitleIDMismatch != expected.TitleIDMismatch ||
+			report.TitleVersionMismatch != expected.TitleVersionMismatch ||
+			report.ContentCountMismatch != expected.ContentCountMismatch ||
+			report.ContentInfoMismatch != expected.ContentInfoMismatch {
+			t.Errorf("%s: report is %+v, expected %+v", test.name, report, expected)
+		}
+
+		if report.Valid() {
+			t.Errorf("%s: tampered WAD is valid", test.name)
+		}
+
+		for position, result := range report.Contents {
+			if result.Status != ContentValid {
+				t.Errorf("%s: content at position %d is %s, expected ok", test.name, position, result.Status)
+			}
+		}
+	}
+}
@@ -2,7 +2,8 @@ package wadlib
 
 import (
 	"bytes"
-	"errors"
+	"fmt"
+	"io"
 	"io/ioutil"
 )
 
@@ -42,24 +43,65 @@ func pad(src []byte) []byte {
 	return resized
 }
 
-// You use readable when you want to stagger contents and not use scary splice related methods.
-type readable struct {
-	data       []byte
-	amountRead uint32
+// You use readableAt when you want to stagger contents and not use scary splice related methods.
+// Contents are read from an io.ReaderAt, which may be a file or bytes already in memory.
+type readableAt struct {
+	source     io.ReaderAt
+	size       int64
+	amountRead int64
 }
 
-// getRange returns a range of data for a size. By default, it is padded to the closest 64 bytes.
-func (r *readable) getRange(size uint32) []byte {
-	current := r.amountRead
-	// We'll want to return the range with actual data by size.
-	selectedRange := r.data[current : current+size]
-	// Then, we'll want to increment amountRead by the padded size.
-	r.amountRead += size + getPadding(size)
+// skipRange skips a range of data for a size, returning the offset it begins at.
+// By default, it is padded to the closest 64 bytes.
+// ErrSectionOverflow is returned should the range extend beyond the data available.
+func (r *readableAt) skipRange(size uint32) (int64, error) {
+	offset := r.amountRead
+	if offset+int64(size) > r.size {
+		return offset, ErrSectionOverflow
+	}
 
-	return selectedRange
+	// We'll want to increment amountRead by the padded size.
+	r.amountRead += int64(size) + int64(getPadding(size))
+	return offset, nil
+}
+
+// getRange reads a range of data for a size. As with skipRange, it is padded to the closest 64 bytes.
+func (r *readableAt) getRange(size uint32) ([]byte, error) {
+	offset, err := r.skipRange(size)
+	if err != nil {
+		return nil, err
+	}
+
+	selectedRange := make([]byte, size)
+	err = readFullAt(r.source, selectedRange, offset)
+	if err != nil {
+		return nil, err
+	}
+
+	return selectedRange, nil
+}
+
+// readFullAt reads exactly len(buf) bytes from the given reader at the given offset.
+func readFullAt(source io.ReaderAt, buf []byte, offset int64) error {
+	// Some readers report EOF for empty reads at their end.
+	if len(buf) == 0 {
+		return nil
+	}
+
+	// Readers may return EOF alongside a complete read.
+	read, err := source.ReadAt(buf, offset)
+	if read == len(buf) {
+		return nil
+	}
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+
+	return err
 }
 
 // LoadWADFromFile takes a path, loads it, and parses the given binary WAD.
+// The entire file is read into memory. Consider opening the file and using LoadWADFromReader otherwise.
 func LoadWADFromFile(filePath string) (*WAD, error) {
 	contents, err := ioutil.ReadFile(filePath)
 	if err != nil {
@@ -69,77 +111,237 @@ func LoadWADFromFile(filePath string) (*WAD, error) {
 	return LoadWAD(contents)
 }
 
-// LoadWAD takes contents and parses the given binary WAD.
-func LoadWAD(contents []byte) (*WAD, error) {
-	// Read the given header. Per Nintendo's configuration, this should only be 32 bytes.
+// validateHeader ensures the given header's sizes fit within a WAD of the given size.
+func validateHeader(header WADHeader, size int64) error {
+	// Simple sanity check. Sections are summed as 64-bit values,
+	// as their sum may otherwise overflow.
+	total := int64(header.HeaderSize)
+	for _, sectionSize := range []uint32{header.CertificateSize, header.CRLSize, header.TicketSize, header.TMDSize, header.DataSize, header.MetaSize} {
+		total += int64(sectionSize)
+	}
+
+	if total > size {
+		return newParseError("header", 0, fmt.Errorf("%w: contents as described in header were in sum larger than contents passed", ErrSectionOverflow))
+	}
+
+	return nil
+}
+
+// hasValidHeaderSize determines whether the given header contents describe a 32-byte header.
+func hasValidHeaderSize(contents []byte) bool {
+	// Per Nintendo's configuration, this should only be 32 bytes.
 	// The first u32 should be from the header, describing its own size.
 	// It's important to check the exact order of these bytes to determine endianness.
-	if !bytes.Equal(contents[0:4], []byte{0x00, 0x00, 0x00, 0x20}) {
-		return nil, errors.New("header should be 32 bytes in default Nintendo configuration")
-	}
+	return len(contents) >= 4 && bytes.Equal(contents[0:4], []byte{0x00, 0x00, 0x00, 0x20})
+}
+
+// LoadWAD takes contents and parses the given binary WAD.
+// Malformed contents result in a ParseError, describing the offset within the WAD at fault.
+func LoadWAD(contents []byte) (*WAD, error) {
+	return loadWAD(bytes.NewReader(contents), int64(len(contents)), false)
+}
 
-	r := readable{
-		data: contents,
+// LoadWADFromReader parses the binary WAD available from the given reader with the given size.
+// The header, certificate chain, ticket and TMD are read immediately,
+// whereas the data for contents is read from the reader only when necessary.
+// As such, the reader must remain available for as long as the returned WAD is used.
+// Malformed contents result in a ParseError, describing the offset within the WAD at fault.
+func LoadWADFromReader(source io.ReaderAt, size int64) (*WAD, error) {
+	return loadWAD(source, size, true)
+}
+
+// loadWAD parses the binary WAD available from the given reader with the given size.
+// If lazy, contents are read from the reader only when necessary. Otherwise, they are read immediately.
+func loadWAD(source io.ReaderAt, size int64, lazy bool) (*WAD, error) {
+	r := readableAt{
+		source: source,
+		size:   size,
 	}
 	wad := WAD{}
 
 	// We'll read the header first as this is in order of the file.
-	// We determined above that the header is 0x20 in length.
-	err := wad.LoadHeader(r.getRange(0x20))
+	// It should be 0x20 in length.
+	headerContents, err := r.getRange(0x20)
 	if err != nil {
-		return nil, err
+		if err == ErrSectionOverflow {
+			err = ErrTruncated
+		}
+		return nil, newParseError("header", 0, err)
+	}
+
+	if !hasValidHeaderSize(headerContents) {
+		return nil, newParseError("header", 0, ErrInvalidHeaderSize)
+	}
+
+	err = wad.LoadHeader(headerContents)
+	if err != nil {
+		return nil, withOffset("header", 0, err)
 	}
 
-	// Simple sanity check.
 	header := wad.Header
-	if int(header.CertificateSize+header.CRLSize+header.TicketSize+header.TMDSize+header.DataSize+header.MetaSize) > len(contents) {
-		return nil, errors.New("contents as described in header were in sum larger than contents passed")
+	err = validateHeader(header, size)
+	if err != nil {
+		return nil, err
+	}
+
+	// getSection returns the next section, noting its offset should it be invalid.
+	var offset int64
+	getSection := func(section string, size uint32) ([]byte, error) {
+		offset = r.amountRead
+		contents, err := r.getRange(size)
+		if err != nil {
+			return nil, newParseError(section, offset, err)
+		}
+
+		return contents, nil
 	}
 
 	// Next, the certificate section and CRL following.
 	// As observed on the Wii, the CRL section is always 0,
 	// along with any references to its version.
-	wad.CertificateChain = r.getRange(header.CertificateSize)
-	wad.CertificateRevocationList = r.getRange(header.CRLSize)
+	wad.CertificateChain, err = getSection("certificate chain", header.CertificateSize)
+	if err != nil {
+		return nil, err
+	}
+
+	wad.CertificateRevocationList, err = getSection("certificate revocation list", header.CRLSize)
+	if err != nil {
+		return nil, err
+	}
 
 	// We'll next load a ticket from our contents into the struct.
-	err = wad.LoadTicket(r.getRange(header.TicketSize))
+	ticket, err := getSection("ticket", header.TicketSize)
 	if err != nil {
 		return nil, err
 	}
 
+	err = wad.LoadTicket(ticket)
+	if err != nil {
+		return nil, withOffset("ticket", offset, err)
+	}
+
 	// Load the TMD following from our contents into the struct.
 	// It needs a separate function to handle dynamic contents listed.
-	err = wad.LoadTMD(r.getRange(header.TMDSize))
+	tmd, err := getSection("TMD", header.TMDSize)
 	if err != nil {
 		return nil, err
 	}
 
+	err = wad.LoadTMD(tmd)
+	if err != nil {
+		return nil, withOffset("TMD", offset, err)
+	}
+
 	// For each content, we want to separate the raw data.
-	err = wad.LoadDataSection(r.getRange(header.DataSize))
+	// When loading lazily, we instead only note where each is located.
+	if lazy {
+		offset, err = r.skipRange(header.DataSize)
+		if err != nil {
+			return nil, newParseError("data section", offset, err)
+		}
+
+		err = wad.loadDataSectionFromReader(io.NewSectionReader(source, offset, int64(header.DataSize)))
+	} else {
+		var data []byte
+		data, err = getSection("data section", header.DataSize)
+		if err != nil {
+			return nil, err
+		}
+
+		err = wad.LoadDataSection(data)
+	}
 	if err != nil {
-		return nil, err
+		return nil, withOffset("data section", offset, err)
 	}
 
 	// We're at the very end and can safely read to the very end of meta, ignoring subsequent data.
-	wad.Meta = r.getRange(header.MetaSize)
+	wad.Meta, err = getSection("meta", header.MetaSize)
+	if err != nil {
+		return nil, err
+	}
 
 	return &wad, nil
 }
 
 // GetWAD returns the bytes necessary for a usable WAD.
 func (w *WAD) GetWAD(wadType WADType) ([]byte, error) {
-	tmd, err := w.GetTMD()
+	var final bytes.Buffer
+	_, err := w.WriteWAD(&final, wadType)
 	if err != nil {
 		return nil, err
 	}
 
+	return final.Bytes(), nil
+}
+
+// countingWriter tracks the amount of data written to its underlying writer.
+type countingWriter struct {
+	writer  io.Writer
+	written int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	written, err := c.writer.Write(p)
+	c.written += int64(written)
+	return written, err
+}
+
+// writePadding writes null bytes to pad the given size to the nearest 0x40/64-byte boundary.
+func writePadding(out io.Writer, size uint32) error {
+	padding := getPadding(size)
+	if padding == 0 {
+		return nil
+	}
+
+	_, err := out.Write(make([]byte, padding))
+	return err
+}
+
+// writePadded writes the given contents, padded to the nearest 0x40/64-byte boundary.
+func writePadded(out io.Writer, contents []byte) error {
+	_, err := out.Write(contents)
+	if err != nil {
+		return err
+	}
+
+	return writePadding(out, uint32(len(contents)))
+}
+
+// WriteTo writes a usable WAD to the given writer, using the WAD type within the current header.
+// If no type is present, WADTypeCommon is used.
+func (w *WAD) WriteTo(out io.Writer) (int64, error) {
+	wadType := w.Header.WADType
+	if wadType == 0 {
+		wadType = WADTypeCommon
+	}
+
+	return w.WriteWAD(out, wadType)
+}
+
+// WriteWAD writes a usable WAD to the given writer.
+// Each section and content is written in order, without first assembling the WAD in memory.
+// Contents loaded lazily are read from their source, and those updated from a reader are encrypted on the fly.
+func (w *WAD) WriteWAD(out io.Writer, wadType WADType) (int64, error) {
+	counter := &countingWriter{
+		writer: out,
+	}
+
+	tmd, err := w.GetTMD()
+	if err != nil {
+		return 0, err
+	}
+
 	ticket, err := w.GetTicket()
 	if err != nil {
-		return nil, err
+		return 0, err
 	}
 
-	data := w.GetDataSection()
+	// Contents within the data section are individually aligned by 64 bytes.
+	var dataSize uint32
+	for _, content := range w.Data {
+		size := content.getRawDataSize()
+		dataSize += size + getPadding(size)
+	}
 
 	// Create a header with our sourced content.
 	header := WADHeader{
@@ -150,23 +352,35 @@ func (w *WAD) GetWAD(wadType WADType) ([]byte, error) {
 		CRLSize:         (uint32)(len(w.CertificateRevocationList)),
 		TicketSize:      (uint32)(len(ticket)),
 		TMDSize:         (uint32)(len(tmd)),
-		DataSize:        (uint32)(len(data)),
+		DataSize:        dataSize,
 		MetaSize:        (uint32)(len(w.Meta)),
 	}
 	w.Header = header
 
 	headerContents, err := w.GetHeader()
 	if err != nil {
-		return nil, err
+		return 0, err
+	}
+
+	for _, section := range [][]byte{headerContents, w.CertificateChain, w.CertificateRevocationList, ticket, tmd} {
+		err = writePadded(counter, section)
+		if err != nil {
+			return counter.written, err
+		}
+	}
+
+	for _, content := range w.Data {
+		written, err := content.writeRawData(counter)
+		if err != nil {
+			return counter.written, err
+		}
+
+		err = writePadding(counter, uint32(written))
+		if err != nil {
+			return counter.written, err
+		}
 	}
 
-	var final []byte
-	final = append(final, pad(headerContents)...)
-	final = append(final, pad(w.CertificateChain)...)
-	final = append(final, pad(w.CertificateRevocationList)...)
-	final = append(final, pad(ticket)...)
-	final = append(final, pad(tmd)...)
-	final = append(final, pad(data)...)
-	final = append(final, pad(w.Meta)...)
-	return final, nil
+	err = writePadded(counter, w.Meta)
+	return counter.written, err
 }
@@ -0,0 +1,298 @@
+package wadlib
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+// testTitleKey is the title key used to encrypt contents of test WADs.
+var testTitleKey = [16]byte{0x00, 0x11, 0x22, 0x33, 0x44, 0x55, 0x66, 0x77, 0x88, 0x99, 0xaa, 0xbb, 0xcc, 0xdd, 0xee, 0xff}
+
+// testWAD returns a WAD holding the given contents, with IDs and indexes following their position.
+// Contents of differing sizes exercise both 16- and 64-byte padding.
+func testWAD(t *testing.T, contents ...[]byte) *WAD {
+	t.Helper()
+
+	b := NewWADBuilder().TitleID(NewTitleID(0x00010001, 0x48414141)).TitleVersion(513).TitleKey(testTitleKey)
+	for index, content := range contents {
+		b.AddContent(content, uint32(index), TitleTypeNormal)
+	}
+
+	wad, err := b.Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	wad.Meta = []byte("meta")
+	return wad
+}
+
+// testWADContents returns contents of sizes unaligned to both 16 and 64 bytes.
+func testWADContents() [][]byte {
+	return [][]byte{
+		bytes.Repeat([]byte{0xaa}, 0x31),
+		bytes.Repeat([]byte{0xbb}, 0x40),
+		bytes.Repeat([]byte{0xcc}, 0x123),
+	}
+}
+
+// testWADBytes returns the bytes of a WAD from testWAD.
+func testWADBytes(t *testing.T, contents ...[]byte) []byte {
+	t.Helper()
+
+	contentsBytes, err := testWAD(t, contents...).GetWAD(WADTypeCommon)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return contentsBytes
+}
+
+func TestLoadWADLazyMatchesEager(t *testing.T) {
+	source := testWADBytes(t, testWADContents()...)
+
+	eager, err := LoadWAD(source)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	lazy, err := LoadWADFromReader(bytes.NewReader(source), int64(len(source)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(eager.Header, lazy.Header) {
+		t.Error("headers differ")
+	}
+	if !bytes.Equal(eager.CertificateChain, lazy.CertificateChain) {
+		t.Error("certificate chains differ")
+	}
+	if !reflect.DeepEqual(eager.Ticket, lazy.Ticket) {
+		t.Error("tickets differ")
+	}
+	if !reflect.DeepEqual(eager.TMD, lazy.TMD) {
+		t.Error("TMDs differ")
+	}
+	if !bytes.Equal(eager.Meta, lazy.Meta) {
+		t.Error("meta differs")
+	}
+
+	if len(eager.Data) != len(lazy.Data) {
+		t.Fatalf("eager WAD has %d contents, lazy WAD has %d", len(eager.Data), len(lazy.Data))
+	}
+
+	for position := range eager.Data {
+		eagerData, err := eager.Data[position].GetRawData()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		lazyData, err := lazy.Data[position].GetRawData()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !bytes.Equal(eagerData, lazyData) {
+			t.Errorf("content at position %d differs", position)
+		}
+
+		if len(eager.Data[position].RawData) == 0 || len(lazy.Data[position].RawData) != 0 {
+			t.Errorf("content at position %d was not loaded as expected", position)
+		}
+	}
+}
+
+func TestWriteWADRoundTrip(t *testing.T) {
+	source := testWADBytes(t, testWADContents()...)
+
+	eager, err := LoadWAD(source)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	lazy, err := LoadWADFromReader(bytes.NewReader(source), int64(len(source)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, test := range []struct {
+		name string
+		wad  *WAD
+	}{
+		{"eager", eager},
+		{"lazy", lazy},
+	} {
+		var written bytes.Buffer
+		size, err := test.wad.WriteWAD(&written, WADTypeCommon)
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+
+		if size != int64(written.Len()) {
+			t.Errorf("%s: reported writing %d bytes, wrote %d", test.name, size, written.Len())
+		}
+
+		if !bytes.Equal(written.Bytes(), source) {
+			t.Errorf("%s: written WAD differs from its source", test.name)
+		}
+	}
+}
+
+// testSectionOffsets returns where the ticket, TMD and data section begin within the given WAD.
+func testSectionOffsets(t *testing.T, source []byte) (int64, int64, int64) {
+	t.Helper()
+
+	wad := WAD{}
+	if err := wad.LoadHeader(source[:0x20]); err != nil {
+		t.Fatal(err)
+	}
+
+	padded := func(size uint32) int64 {
+		return int64(size) + int64(getPadding(size))
+	}
+
+	ticket := padded(wad.Header.HeaderSize) + padded(wad.Header.CertificateSize) + padded(wad.Header.CRLSize)
+	tmd := ticket + padded(wad.Header.TicketSize)
+	data := tmd + padded(wad.Header.TMDSize)
+	return ticket, tmd, data
+}
+
+func TestLoadWADParseErrors(t *testing.T) {
+	source := testWADBytes(t, testWADContents()...)
+	ticketOffset, tmdOffset, dataOffset := testSectionOffsets(t, source)
+
+	// Within a TMD signed with RSA-2048, the amount of contents is at 0x1de, with records beginning at 0x1e4.
+	// Each record is 36 bytes, holding its index at 0x4 and size at 0x8.
+	const (
+		numberOfContents = 0x1de
+		firstRecord      = 0x1e4
+		recordSize       = 36
+	)
+	if binary.BigEndian.Uint16(source[tmdOffset+numberOfContents:]) != 3 {
+		t.Fatal("TMD is not laid out as expected")
+	}
+
+	for _, test := range []struct {
+		name     string
+		corrupt  func([]byte) []byte
+		expected error
+		offset   int64
+	}{
+		{
+			name: "truncated header",
+			corrupt: func(wad []byte) []byte {
+				return wad[:0x10]
+			},
+			expected: ErrTruncated,
+			offset:   0,
+		},
+		{
+			name: "invalid header size",
+			corrupt: func(wad []byte) []byte {
+				binary.BigEndian.PutUint32(wad[0x00:], 0x40)
+				return wad
+			},
+			expected: ErrInvalidHeaderSize,
+			offset:   0,
+		},
+		{
+			name: "sections larger than WAD",
+			corrupt: func(wad []byte) []byte {
+				binary.BigEndian.PutUint32(wad[0x18:], 0xffffffff)
+				return wad
+			},
+			expected: ErrSectionOverflow,
+			offset:   0,
+		},
+		{
+			// The header is padded to 0x40 bytes, which its sizes do not account for.
+			name: "truncated certificate chain",
+			corrupt: func(wad []byte) []byte {
+				certSize := binary.BigEndian.Uint32(wad[0x08:])
+				for offset := 0x0c; offset < 0x20; offset += 4 {
+					binary.BigEndian.PutUint32(wad[offset:], 0)
+				}
+				return wad[:0x20+certSize]
+			},
+			expected: ErrSectionOverflow,
+			offset:   0x40,
+		},
+		{
+			// A ticket too short for its body is truncated following its signature.
+			name: "truncated ticket",
+			corrupt: func(wad []byte) []byte {
+				binary.BigEndian.PutUint32(wad[0x10:], 0x180)
+				return wad
+			},
+			expected: ErrTruncated,
+			offset:   ticketOffset + 0x140,
+		},
+		{
+			name: "TMD listing more contents than present",
+			corrupt: func(wad []byte) []byte {
+				binary.BigEndian.PutUint16(wad[tmdOffset+numberOfContents:], 100)
+				return wad
+			},
+			expected: ErrTruncated,
+			offset:   tmdOffset + firstRecord,
+		},
+		{
+			// The first two contents each occupy 0x40 bytes.
+			name: "content larger than data section",
+			corrupt: func(wad []byte) []byte {
+				binary.BigEndian.PutUint64(wad[tmdOffset+firstRecord+2*recordSize+8:], 0x10000)
+				return wad
+			},
+			expected: ErrSectionOverflow,
+			offset:   dataOffset + 0x80,
+		},
+		{
+			name: "duplicate content index",
+			corrupt: func(wad []byte) []byte {
+				binary.BigEndian.PutUint16(wad[tmdOffset+firstRecord+recordSize+4:], 0)
+				return wad
+			},
+			expected: ErrDuplicateContentIndex,
+			offset:   dataOffset + 0x40,
+		},
+		{
+			// Meta is padded to 0x40 bytes, so its unpadded size fits.
+			name: "truncated meta",
+			corrupt: func(wad []byte) []byte {
+				return wad[:len(wad)-0x40+1]
+			},
+			expected: ErrSectionOverflow,
+			offset:   int64(len(source) - 0x40),
+		},
+	} {
+		corrupted := test.corrupt(append([]byte{}, source...))
+
+		eager := func() (*WAD, error) {
+			return LoadWAD(corrupted)
+		}
+		lazy := func() (*WAD, error) {
+			return LoadWADFromReader(bytes.NewReader(corrupted), int64(len(corrupted)))
+		}
+
+		for _, load := range []func() (*WAD, error){eager, lazy} {
+			_, err := load()
+			if !errors.Is(err, test.expected) {
+				t.Errorf("%s: returned %v, expected %v", test.name, err, test.expected)
+				continue
+			}
+
+			var parseErr *ParseError
+			if !errors.As(err, &parseErr) {
+				t.Errorf("%s: returned %v, expected a ParseError", test.name, err)
+				continue
+			}
+
+			if parseErr.Offset != test.offset {
+				t.Errorf("%s: reported offset 0x%x, expected 0x%x", test.name, parseErr.Offset, test.offset)
+			}
+		}
+	}
+}
@@ -0,0 +1,261 @@
+// Package yaz0 reads and writes data compressed with Yaz0.
+package yaz0
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+
+	"github.com/wii-tools/wadlib/internal/lzss"
+)
+
+var (
+	ErrInvalidMagic = errors.New("data is not compressed with Yaz0")
+	ErrSizeMismatch = errors.New("decompressed data does not match the size within its header")
+)
+
+// Magic precedes the header of compressed data.
+var Magic = [4]byte{'Y', 'a', 'z', '0'}
+
+// maxLength is the longest back-reference Yaz0 can encode.
+const maxLength = 0x111
+
+// binaryHeader describes the byte-level format of the header preceding compressed data.
+type binaryHeader struct {
+	Magic [4]byte
+	Size  uint32
+	// Reserved is typically null, although some titles store an alignment here.
+	Reserved [8]byte
+}
+
+// Reader decompresses Yaz0 data from an underlying reader as it is read.
+type Reader struct {
+	source         *bufio.Reader
+	size           int64
+	window         lzss.Window
+	flags          byte
+	remainingFlags int
+	err            error
+}
+
+// NewReader returns a Reader decompressing from the given reader.
+// Its header is read immediately.
+func NewReader(source io.Reader) (*Reader, error) {
+	r := &Reader{
+		source: bufio.NewReader(source),
+	}
+
+	var header binaryHeader
+	err := binary.Read(r.source, binary.BigEndian, &header)
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		return nil, ErrInvalidMagic
+	} else if err != nil {
+		return nil, err
+	}
+
+	if header.Magic != Magic {
+		return nil, ErrInvalidMagic
+	}
+
+	r.size = int64(header.Size)
+	return r, nil
+}
+
+// unexpected converts EOF to ErrUnexpectedEOF, as compressed data has ended early.
+func unexpected(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+
+	return err
+}
+
+// Size returns the decompressed size of this data.
+func (r *Reader) Size() int64 {
+	return r.size
+}
+
+func (r *Reader) Read(p []byte) (int, error) {
+	for !r.window.Pending() && r.err == nil {
+		if r.window.Written() >= r.size {
+			r.err = io.EOF
+			break
+		}
+
+		r.err = r.next()
+	}
+
+	if r.window.Pending() {
+		return r.window.Read(p), nil
+	}
+	return 0, r.err
+}
+
+// next decompresses the next literal or back-reference.
+func (r *Reader) next() error {
+	// Every 8 literals or back-references are preceded by their flags, from the most significant bit.
+	// Unlike LZ77, a set bit describes a literal.
+	if r.remainingFlags == 0 {
+		flags, err := r.source.ReadByte()
+		if err != nil {
+			return unexpected(err)
+		}
+
+		r.flags = flags
+		r.remainingFlags = 8
+	}
+
+	literal := r.flags&0x80 != 0
+	r.flags <<= 1
+	r.remainingFlags--
+
+	if literal {
+		value, err := r.source.ReadByte()
+		if err != nil {
+			return unexpected(err)
+		}
+
+		r.window.Literal(value)
+		return nil
+	}
+
+	var reference [3]byte
+	_, err := io.ReadFull(r.source, reference[:2])
+	if err != nil {
+		return unexpected(err)
+	}
+
+	// A length of 0 within the upper 4 bits indicates a further byte holds the length.
+	distance := (int(reference[0]&0xf)<<8 | int(reference[1])) + 1
+	length := int(reference[0]>>4) + 2
+	if reference[0]>>4 == 0 {
+		_, err = io.ReadFull(r.source, reference[2:3])
+		if err != nil {
+			return unexpected(err)
+		}
+
+		length = int(reference[2]) + 0x12
+	}
+
+	// Back-references must not exceed the size within our header.
+	if remaining := r.size - r.window.Written(); int64(length) > remaining {
+		return ErrSizeMismatch
+	}
+
+	return r.window.Copy(distance, length)
+}
+
+// Writer compresses data written to it with Yaz0.
+// As the header holds the decompressed size, data is compressed and written once the Writer is closed.
+type Writer struct {
+	destination io.Writer
+	buffer      bytes.Buffer
+	closed      bool
+}
+
+// NewWriter returns a Writer compressing to the given writer.
+func NewWriter(destination io.Writer) *Writer {
+	return &Writer{
+		destination: destination,
+	}
+}
+
+func (w *Writer) Write(p []byte) (int, error) {
+	if w.closed {
+		return 0, io.ErrClosedPipe
+	}
+
+	return w.buffer.Write(p)
+}
+
+// Close compresses all data written and writes it to the underlying writer.
+// It does not close the underlying writer.
+func (w *Writer) Close() error {
+	if w.closed {
+		return nil
+	}
+	w.closed = true
+
+	data := w.buffer.Bytes()
+	out := bufio.NewWriter(w.destination)
+	err := binary.Write(out, binary.BigEndian, binaryHeader{
+		Magic: Magic,
+		Size:  uint32(len(data)),
+	})
+	if err != nil {
+		return err
+	}
+
+	matcher := lzss.NewMatcher(data, maxLength)
+	var block [1 + 8*3]byte
+	for position := 0; position < len(data); {
+		// Every 8 literals or back-references are preceded by their flags.
+		flags := byte(0)
+		blockSize := 1
+		for item := 0; item < 8 && position < len(data); item++ {
+			length, distance := matcher.Find(position)
+			if length == 0 {
+				flags |= 0x80 >> item
+				block[blockSize] = data[position]
+				blockSize++
+				length = 1
+			} else {
+				distance--
+				if length < 0x12 {
+					block[blockSize] = byte(length-2)<<4 | byte(distance>>8)
+					block[blockSize+1] = byte(distance)
+					blockSize += 2
+				} else {
+					block[blockSize] = byte(distance >> 8)
+					block[blockSize+1] = byte(distance)
+					block[blockSize+2] = byte(length - 0x12)
+					blockSize += 3
+				}
+			}
+
+			for end := position + length; position < end; position++ {
+				matcher.Insert(position)
+			}
+		}
+
+		block[0] = flags
+		out.Write(block[:blockSize])
+	}
+
+	return out.Flush()
+}
+
+// Decompress returns the decompressed contents of the given Yaz0 data.
+func Decompress(data []byte) ([]byte, error) {
+	r, err := NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+
+	var decompressed bytes.Buffer
+	_, err = io.Copy(&decompressed, r)
+	if err != nil {
+		return nil, err
+	}
+
+	return decompressed.Bytes(), nil
+}
+
+// Compress returns the given data compressed with Yaz0.
+func Compress(data []byte) ([]byte, error) {
+	var compressed bytes.Buffer
+	w := NewWriter(&compressed)
+	_, err := w.Write(data)
+	if err != nil {
+		return nil, err
+	}
+
+	err = w.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	return compressed.Bytes(), nil
+}
@@ -0,0 +1,140 @@
+package yaz0
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"testing"
+)
+
+// header returns a Yaz0 header for the given decompressed size.
+func header(size byte) []byte {
+	return []byte{'Y', 'a', 'z', '0', 0x00, 0x00, 0x00, size, 0, 0, 0, 0, 0, 0, 0, 0}
+}
+
+// These samples are laid out as Nintendo's encoders emit them.
+var samples = []struct {
+	name       string
+	compressed []byte
+	expected   []byte
+}{
+	{
+		// Literals "abc", followed by a 2-byte back-reference of 9 bytes at a distance of 3.
+		name:       "short reference",
+		compressed: append(header(0x0c), 0xe0, 'a', 'b', 'c', 0x70, 0x02),
+		expected:   []byte("abcabcabcabc"),
+	},
+	{
+		// The literal "a", followed by a 3-byte back-reference of 31 bytes at a distance of 1.
+		name:       "long reference",
+		compressed: append(header(0x20), 0x80, 'a', 0x00, 0x00, 0x0d),
+		expected:   bytes.Repeat([]byte("a"), 0x20),
+	},
+}
+
+func TestDecompressSamples(t *testing.T) {
+	for _, sample := range samples {
+		decompressed, err := Decompress(sample.compressed)
+		if err != nil {
+			t.Errorf("%s: %v", sample.name, err)
+			continue
+		}
+
+		if !bytes.Equal(decompressed, sample.expected) {
+			t.Errorf("%s: decompressed to %q, expected %q", sample.name, decompressed, sample.expected)
+		}
+	}
+}
+
+// testData returns compressible data of the given size.
+func testData(size int) []byte {
+	random := rand.New(rand.NewSource(1))
+	data := make([]byte, size)
+	for i := range data {
+		if random.Intn(4) == 0 {
+			data[i] = byte(random.Intn(256))
+		} else if i >= 8 {
+			data[i] = data[i-1-random.Intn(8)]
+		}
+	}
+	return data
+}
+
+func TestRoundTrip(t *testing.T) {
+	inputs := map[string][]byte{
+		"empty":     {},
+		"single":    {0x42},
+		"text":      []byte("The quick brown fox jumps over the lazy dog. The quick brown fox jumps over the lazy dog."),
+		"random":    testData(0x20000),
+		"long runs": bytes.Repeat([]byte{0x00}, 0x20000),
+	}
+
+	for name, input := range inputs {
+		compressed, err := Compress(input)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+
+		if !bytes.Equal(compressed[:4], Magic[:]) {
+			t.Errorf("%s: compressed data is not preceded by the magic", name)
+		}
+
+		decompressed, err := Decompress(compressed)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+
+		if !bytes.Equal(decompressed, input) {
+			t.Errorf("%s: data differs after round trip", name)
+		}
+	}
+}
+
+func TestReader(t *testing.T) {
+	input := testData(0x1000)
+	compressed, err := Compress(input)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := NewReader(bytes.NewReader(compressed))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if r.Size() != int64(len(input)) {
+		t.Errorf("header describes size %d, expected %d", r.Size(), len(input))
+	}
+
+	decompressed, err := ioutil.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(decompressed, input) {
+		t.Error("data differs after round trip")
+	}
+}
+
+func TestTruncated(t *testing.T) {
+	for _, sample := range samples {
+		_, err := Decompress(sample.compressed[:len(sample.compressed)-1])
+		if err != io.ErrUnexpectedEOF {
+			t.Errorf("%s: returned %v, expected io.ErrUnexpectedEOF", sample.name, err)
+		}
+	}
+
+	// A truncated header cannot be identified as Yaz0.
+	_, err := Decompress(samples[0].compressed[:8])
+	if err != ErrInvalidMagic {
+		t.Errorf("truncated header returned %v, expected ErrInvalidMagic", err)
+	}
+}
+
+func TestInvalidMagic(t *testing.T) {
+	_, err := Decompress([]byte("Yay0\x00\x00\x00\x04\x00\x00\x00\x00\x00\x00\x00\x00"))
+	if err != ErrInvalidMagic {
+		t.Errorf("returned %v, expected ErrInvalidMagic", err)
+	}
+}